@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Creates `setupCollection` variable that uses the `setup` collection from MongoDB instance, which records the
+// one-time setup steps that were completed.
+var setupCollection *mongo.Collection = database.OpenCollection(database.Client, "setup")
+
+// ID of the `setupCollection` document recording the first admin was promoted.
+const bootstrapAdminSetupID = "bootstrap_admin"
+
+// Body of the `/users/:user_id/user-type` request.
+type userTypeRequest struct {
+	UserType string `json:"user_type" validate:"required,eq=ADMIN|eq=USER"`
+}
+
+// Body of the `/setup/admin` request.
+type bootstrapAdminRequest struct {
+	Token string `json:"token" validate:"required"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+// Handler function for the `/users/:user_id/user-type` route, which promotes a user to `ADMIN` or demotes them to `USER`.
+func SetUserType() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request userTypeRequest
+		var user models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Finds the user to promote or demote.
+		userId := c.Param("user_id")
+		if err := userCollection.FindOne(ctx, bson.M{"userid": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the user doesn't exist."})
+			return
+		}
+
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "usertype", Value: request.UserType},
+			{Key: "updatedat", Value: time.Now()},
+		}}}
+
+		// Demoted users also lose the `ADMIN` role, but the last admin can never be demoted.
+		if request.UserType == models.RoleUser {
+			otherAdmins, err := userCollection.CountDocuments(ctx, bson.M{
+				"userid": bson.M{"$ne": userId},
+				"$or":    bson.A{bson.M{"usertype": models.RoleAdmin}, bson.M{"roles": models.RoleAdmin}},
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while counting admins."})
+				return
+			}
+			if otherAdmins == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the last admin can't be demoted."})
+				return
+			}
+			update = append(update, bson.E{Key: "$pull", Value: bson.D{{Key: "roles", Value: models.RoleAdmin}}})
+		}
+
+		_, err := userCollection.UpdateOne(ctx, bson.M{"userid": userId}, update)
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while changing the user type."})
+			return
+		}
+
+		action := "promote"
+		if request.UserType == models.RoleUser {
+			action = "demote"
+		}
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditRoleChange,
+			TargetID: userId,
+			Details:  map[string]string{"action": action, "from": *user.UserType, "to": request.UserType},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"user_id": userId, "user_type": request.UserType})
+	}
+}
+
+// Handler function for the `/setup/admin` route, which promotes the first admin with the `BOOTSTRAP_ADMIN_TOKEN`.
+// It only ever succeeds once.
+func BootstrapAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request bootstrapAdminRequest
+		var user models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Checks the bootstrap token, the same response is given when the setup is disabled.
+		if !helpers.CheckBootstrapToken(request.Token) {
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:    models.AuditRoleChange,
+				Outcome: models.AuditOutcomeFailure,
+				Details: map[string]string{"action": "bootstrap", "reason": "invalid_token"},
+			})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the setup token is invalid."})
+			return
+		}
+
+		// Finds the user to promote, who must have signed up first, outside of any organization with its own emails.
+		if err := userCollection.FindOne(ctx, helpers.EmailFilter(request.Email, "")).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the user doesn't exist."})
+			return
+		}
+
+		// Records the setup step first, which only succeeds once, so the token can't be used again.
+		_, err := setupCollection.InsertOne(ctx, bson.M{"_id": bootstrapAdminSetupID, "userid": user.UserID, "createdat": time.Now()})
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the first admin was already set up."})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while setting up the admin."})
+			return
+		}
+
+		_, err = userCollection.UpdateOne(ctx, bson.M{"userid": user.UserID}, bson.D{{Key: "$set", Value: bson.D{
+			{Key: "usertype", Value: models.RoleAdmin},
+			{Key: "updatedat", Value: time.Now()},
+		}}})
+		// Error handling for the above `UpdateOne()` function, which frees the setup step for another attempt.
+		if err != nil {
+			setupCollection.DeleteOne(ctx, bson.M{"_id": bootstrapAdminSetupID})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while setting up the admin."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditRoleChange,
+			ActorID:  user.UserID,
+			TargetID: user.UserID,
+			Details:  map[string]string{"action": "bootstrap", "from": *user.UserType, "to": models.RoleAdmin},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"user_id": user.UserID, "user_type": models.RoleAdmin})
+	}
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creates `auditCollection` variable that uses the `audit_log` collection from MongoDB instance.
+var auditCollection *mongo.Collection = database.OpenCollection(database.Client, "audit_log")
+
+// Handler function for the `/audit/events` route.
+func ListAuditEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// Builds the filter from the query parameters.
+		filter, err := auditEventFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Sets the value of the `recordPerPage` query parameter, if it is present.
+		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
+		if err != nil || recordPerPage < 1 || recordPerPage > 1000 {
+			// Default `recordsPerPage` value
+			recordPerPage = 50
+		}
+
+		// Sets the value of the `page` query parameter, if it is present.
+		page, err := strconv.Atoi(c.Query("page"))
+		if err != nil || page < 1 {
+			// Default `page` value
+			page = 1
+		}
+
+		// Counts the matching events.
+		totalCount, err := auditCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing audit events."})
+			return
+		}
+
+		// Finds the requested page of events, newest first.
+		cursor, err := auditCollection.Find(ctx, filter, options.Find().
+			SetSort(bson.D{{Key: "createdat", Value: -1}, {Key: "_id", Value: -1}}).
+			SetSkip(int64((page-1)*recordPerPage)).
+			SetLimit(int64(recordPerPage)),
+		)
+		// Error handling for the above `Find()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing audit events."})
+			return
+		}
+
+		events := []models.AuditEvent{}
+		if err = cursor.All(ctx, &events); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing audit events."})
+			return
+		}
+
+		// Records that an admin read the audit log.
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditAdminReadAudit,
+			Details: map[string]string{"page": strconv.Itoa(page), "record_per_page": strconv.Itoa(recordPerPage)},
+		})
+
+		// Returns a code 200 status and the page of events.
+		c.JSON(http.StatusOK, gin.H{"total_count": totalCount, "audit_events": events})
+	}
+}
+
+// Handler function for the `/audit/events/export` route, which streams every matching event as JSON lines.
+func ExportAuditEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 10 minutes, since exports can be large.
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+
+		// Builds the filter from the query parameters.
+		filter, err := auditEventFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Finds the matching events, oldest first.
+		cursor, err := auditCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}))
+		// Error handling for the above `Find()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst exporting audit events."})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		// Records the export before streaming, since the status can't change once the body is written.
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditAdminReadAudit,
+			Details: map[string]string{"export": "jsonl"},
+		})
+
+		// Writes one JSON document per line, as the events are read from the cursor.
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="audit_log.jsonl"`)
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		for cursor.Next(ctx) {
+			var event models.AuditEvent
+			if err := cursor.Decode(&event); err != nil {
+				return
+			}
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// Handler function for the `/audit/verify` route, which walks the audit chain and reports the first broken link.
+func VerifyAuditChain() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 10 minutes, since the whole chain is read.
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+
+		report, err := helpers.VerifyAuditChain(ctx)
+		// Error handling for the above `VerifyAuditChain()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst verifying the audit log."})
+			return
+		}
+
+		// Records the verification and its result.
+		outcome := models.AuditOutcomeSuccess
+		if !report.Valid {
+			outcome = models.AuditOutcomeFailure
+		}
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditAdminReadAudit,
+			Outcome: outcome,
+			Details: map[string]string{"verify": strconv.FormatBool(report.Valid)},
+		})
+
+		// Returns a code 200 status and the report.
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// Builds the audit log filter from the `type`, `actor_id`, `target_id`, `outcome`, `from` and `to` query parameters.
+// `from` and `to` are RFC 3339 times.
+func auditEventFilter(c *gin.Context) (bson.M, error) {
+	filter := bson.M{}
+
+	for parameter, field := range map[string]string{"type": "type", "actor_id": "actorid", "target_id": "targetid", "outcome": "outcome"} {
+		if value := c.Query(parameter); value != "" {
+			filter[field] = value
+		}
+	}
+
+	createdAt := bson.M{}
+	if from := c.Query("from"); from != "" {
+		parsed, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return nil, err
+		}
+		createdAt["$gte"] = parsed
+	}
+	if to := c.Query("to"); to != "" {
+		parsed, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return nil, err
+		}
+		createdAt["$lt"] = parsed
+	}
+	if len(createdAt) > 0 {
+		filter["createdat"] = createdAt
+	}
+
+	return filter, nil
+}
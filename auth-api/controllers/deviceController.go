@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Outcome of comparing the client of a sign in against the known devices and last location of a user.
+type loginAssessment struct {
+	// Index of the device in the user's `KnownDevices`, or -1 if it is new.
+	DeviceIndex int
+	Fingerprint string
+	DeviceHash  string
+	IPPrefix    string
+	// Location of the sign in, nil if it couldn't be resolved.
+	Location *models.LoginLocation
+	// Whether the user couldn't have travelled from their last sign in location in time.
+	ImpossibleTravel bool
+}
+
+// Fingerprints the client of the request `c`, issuing it a device cookie if it has none, and compares it against
+// the known devices and last sign in location of `user`.
+func assessLogin(c *gin.Context, user models.User) loginAssessment {
+	// Reads the device cookie, or issues one so the device can be recognised on its next sign in.
+	// An issued cookie is kept on the context, so a request is never issued two.
+	deviceID, err := c.Cookie(helpers.DeviceCookie)
+	if err != nil || deviceID == "" {
+		deviceID = c.GetString(helpers.DeviceCookie)
+	}
+	if deviceID == "" {
+		if deviceID, err = helpers.GenerateRandomToken(32); err != nil {
+			deviceID = ""
+		} else {
+			c.Set(helpers.DeviceCookie, deviceID)
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(helpers.DeviceCookie, deviceID, helpers.DeviceCookieDays*24*60*60, "/", "", c.Request.TLS != nil, true)
+		}
+	}
+
+	assessment := loginAssessment{
+		IPPrefix: helpers.IPPrefix(c.ClientIP()),
+		Location: helpers.LookupLoginLocation(c.ClientIP(), time.Now().UTC()),
+	}
+	assessment.Fingerprint = helpers.DeviceFingerprint(c.Request.UserAgent(), assessment.IPPrefix)
+	if deviceID != "" {
+		assessment.DeviceHash = helpers.HashToken(deviceID)
+	}
+	assessment.DeviceIndex = helpers.FindKnownDevice(user.KnownDevices, assessment.Fingerprint, assessment.DeviceHash)
+
+	if user.LastLoginLocation != nil && assessment.Location != nil {
+		assessment.ImpossibleTravel = helpers.ImpossibleTravel(*user.LastLoginLocation, *assessment.Location)
+	}
+
+	return assessment
+}
+
+// Flags a sign in of `user` that couldn't have been made by the same person as their last one. Users with MFA are
+// challenged for it anyway, users without it must sign in again with a method proving they own the account.
+// Returns whether the sign in may go on.
+func checkSuspiciousLogin(c *gin.Context, user models.User, assessment loginAssessment) bool {
+	if !assessment.ImpossibleTravel {
+		return true
+	}
+
+	helpers.RecordAuditEvent(c, models.AuditEvent{
+		Type:     models.AuditSuspiciousLogin,
+		ActorID:  user.UserID,
+		TargetID: user.UserID,
+		Details: map[string]string{
+			"reason":        "impossible_travel",
+			"from_country":  user.LastLoginLocation.Country,
+			"from_city":     user.LastLoginLocation.City,
+			"country":       assessment.Location.Country,
+			"city":          assessment.Location.City,
+			"mfa_step_up":   fmt.Sprint(user.MFAEnabled),
+			"ip_prefix":     assessment.IPPrefix,
+			"new_device":    fmt.Sprint(assessment.DeviceIndex < 0),
+			"last_login_at": user.LastLoginLocation.At.Format(time.RFC3339),
+		},
+	})
+	body := fmt.Sprintf("Someone signed in to your account with your password from %s, shortly after you signed in from %s.\n\nIf this wasn't you, change your password right away.", describeLocation(assessment.Location), describeLocation(user.LastLoginLocation))
+	go sendSecurityEmail(*user.Email, "Unusual sign-in attempt", body)
+
+	if user.MFAEnabled {
+		return true
+	}
+
+	// Lists the sign in methods that prove ownership of the account beyond the password.
+	methods := []string{"magic_link"}
+	if len(user.WebAuthnCredentials) > 0 {
+		methods = append(methods, "webauthn")
+	}
+
+	// Returns a code 403 status.
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":            "this sign in looks unusual, please confirm it with another sign in method.",
+		"step_up_required": true,
+		"step_up_methods":  methods,
+	})
+	return false
+}
+
+// Remembers the device and location of a completed sign in of `user`. New devices are recorded, and the user is
+// emailed about them unless it is their first sign in.
+func recordLoginDevice(c *gin.Context, user models.User) {
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	assessment := assessLogin(c, user)
+	now := time.Now().UTC()
+
+	// Moves the device to the front of the list, adding it if it is new, and forgets the least recently seen ones.
+	device := models.KnownDevice{
+		Fingerprint: assessment.Fingerprint,
+		UserAgent:   c.Request.UserAgent(),
+		IPPrefix:    assessment.IPPrefix,
+		FirstSeenAt: now,
+	}
+	devices := []models.KnownDevice{}
+	if assessment.DeviceIndex >= 0 {
+		device = user.KnownDevices[assessment.DeviceIndex]
+		device.Fingerprint = assessment.Fingerprint
+		device.UserAgent = c.Request.UserAgent()
+		device.IPPrefix = assessment.IPPrefix
+	}
+	if assessment.DeviceHash != "" {
+		device.DeviceHash = assessment.DeviceHash
+	}
+	if assessment.Location != nil {
+		device.Country = assessment.Location.Country
+		device.City = assessment.Location.City
+	}
+	device.LastSeenAt = now
+	devices = append(devices, device)
+	for index, known := range user.KnownDevices {
+		if index != assessment.DeviceIndex && len(devices) < helpers.MAX_KNOWN_DEVICES {
+			devices = append(devices, known)
+		}
+	}
+
+	update := bson.D{{Key: "knowndevices", Value: devices}}
+	if assessment.Location != nil {
+		update = append(update, bson.E{Key: "lastloginlocation", Value: assessment.Location})
+	}
+	_, err := userCollection.UpdateOne(ctx, bson.M{"userid": user.UserID}, bson.D{{Key: "$set", Value: update}})
+	if err != nil {
+		log.Println(err)
+	}
+
+	if assessment.DeviceIndex >= 0 {
+		return
+	}
+
+	helpers.RecordAuditEvent(c, models.AuditEvent{
+		Type:     models.AuditNewDevice,
+		ActorID:  user.UserID,
+		TargetID: user.UserID,
+		Details: map[string]string{
+			"user_agent": device.UserAgent,
+			"ip_prefix":  device.IPPrefix,
+			"country":    device.Country,
+			"city":       device.City,
+		},
+	})
+
+	// A user's first device isn't worth an email.
+	if len(user.KnownDevices) > 0 {
+		body := fmt.Sprintf("Your account was just signed in to from a new device.\n\nDevice: %s\nLocation: %s\nTime: %s\n\nIf this was you, you can ignore this email. Otherwise, change your password right away.", device.UserAgent, describeLocation(assessment.Location), now.Format(time.RFC1123))
+		go sendSecurityEmail(*user.Email, "New sign-in to your account", body)
+	}
+}
+
+// Returns a readable description of `location`.
+func describeLocation(location *models.LoginLocation) string {
+	switch {
+	case location == nil:
+		return "an unknown location"
+	case location.City != "":
+		return location.City + ", " + location.Country
+	case location.Country != "":
+		return location.Country
+	}
+
+	return "an unknown location"
+}
+
+// Sends an email about the security of an account, meant to run in the background so SMTP latency doesn't show in
+// response times.
+func sendSecurityEmail(email, subject, body string) {
+	if err := helpers.SendEmail(email, subject, body); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,428 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creates `groupCollection` variable that uses the `group` collection from MongoDB instance.
+var groupCollection *mongo.Collection = database.OpenCollection(database.Client, "group")
+
+// Serializes the nesting of groups, so two concurrent changes can't each pass the cycle check and create a cycle together.
+var groupNestingMutex sync.Mutex
+
+// Body of the `/groups/:group_id` update request.
+type groupUpdateRequest struct {
+	Name        *string   `json:"name" validate:"omitempty,min=2,max=50"`
+	Description *string   `json:"description" validate:"omitempty,max=200"`
+	Roles       *[]string `json:"roles" validate:"omitempty,dive,required"`
+}
+
+// Handler function for the `POST /groups` route.
+func CreateGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var group models.Group
+		defer cancel()
+
+		// Parses and validates the `group` variable from the HTTP request.
+		if err := c.BindJSON(&group); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(group); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+		if group.Roles == nil {
+			group.Roles = []string{}
+		}
+
+		// Only roles whose permissions are all held by the caller can be given to the group's future members.
+		if !authorizeGrantedRoles(ctx, c, group.Roles, "") {
+			return
+		}
+
+		// Groups are nested through `/groups/:group_id/groups/:child_id`, where cycles are checked.
+		group.Groups = []string{}
+		group.ID = primitive.NewObjectID()
+		group.GroupID = group.ID.Hex()
+		group.CreatedAt = time.Now().UTC()
+		group.UpdatedAt = group.CreatedAt
+
+		_, err := groupCollection.InsertOne(ctx, group)
+		// Error handling for the above `InsertOne()` function.
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a group with this name already exists."})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating the group."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditGroupChange,
+			Details: map[string]string{"action": "create", "group_id": group.GroupID, "roles": strings.Join(group.Roles, " ")},
+		})
+
+		// Returns a code 201 status and the created group.
+		c.JSON(http.StatusCreated, group)
+	}
+}
+
+// Handler function for the `GET /groups` route.
+func GetGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := groupCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		// Error handling for the above `Find()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing groups."})
+			return
+		}
+
+		groups := []models.Group{}
+		if err = cursor.All(ctx, &groups); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing groups."})
+			return
+		}
+
+		// Returns a code 200 status and the groups.
+		c.JSON(http.StatusOK, groups)
+	}
+}
+
+// Handler function for the `GET /groups/:group_id` route, which also lists the direct members of the group.
+func GetGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var group models.Group
+		defer cancel()
+
+		groupId := c.Param("group_id")
+		if err := groupCollection.FindOne(ctx, bson.M{"groupid": groupId}).Decode(&group); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the group doesn't exist."})
+			return
+		}
+
+		// Finds the users and groups that are direct members of the group.
+		var users []models.User
+		var children []models.Group
+		userCursor, err := userCollection.Find(ctx, bson.M{"groups": groupId}, options.Find().SetProjection(bson.M{"userid": 1}))
+		if err == nil {
+			err = userCursor.All(ctx, &users)
+		}
+		if err == nil {
+			var groupCursor *mongo.Cursor
+			if groupCursor, err = groupCollection.Find(ctx, bson.M{"groups": groupId}, options.Find().SetProjection(bson.M{"groupid": 1})); err == nil {
+				err = groupCursor.All(ctx, &children)
+			}
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing the group members."})
+			return
+		}
+
+		memberUsers := []string{}
+		for _, user := range users {
+			memberUsers = append(memberUsers, user.UserID)
+		}
+		memberGroups := []string{}
+		for _, child := range children {
+			memberGroups = append(memberGroups, child.GroupID)
+		}
+
+		// Returns a code 200 status, the group and its members.
+		c.JSON(http.StatusOK, gin.H{"group": group, "member_users": memberUsers, "member_groups": memberGroups})
+	}
+}
+
+// Handler function for the `PATCH /groups/:group_id` route, which renames a group or replaces its description or roles.
+func UpdateGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request groupUpdateRequest
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		update := bson.D{{Key: "updatedat", Value: time.Now().UTC()}}
+		details := map[string]string{"action": "update", "group_id": c.Param("group_id")}
+		if request.Name != nil {
+			update = append(update, bson.E{Key: "name", Value: *request.Name})
+			details["name"] = *request.Name
+		}
+		if request.Description != nil {
+			update = append(update, bson.E{Key: "description", Value: *request.Description})
+		}
+		if request.Roles != nil {
+			// Only roles whose permissions are all held by the caller can be given to the group's members.
+			if !authorizeGrantedRoles(ctx, c, *request.Roles, "") {
+				return
+			}
+			update = append(update, bson.E{Key: "roles", Value: *request.Roles})
+			details["roles"] = strings.Join(*request.Roles, " ")
+		}
+
+		result, err := groupCollection.UpdateOne(ctx, bson.M{"groupid": c.Param("group_id")}, bson.D{{Key: "$set", Value: update}})
+		// Error handling for the above `UpdateOne()` function.
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a group with this name already exists."})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while updating the group."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the group doesn't exist."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditGroupChange, Details: details})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"updated": c.Param("group_id")})
+	}
+}
+
+// Handler function for the `DELETE /groups/:group_id` route, which also removes the group from its members.
+func DeleteGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		groupId := c.Param("group_id")
+		result, err := groupCollection.DeleteOne(ctx, bson.M{"groupid": groupId})
+		// Error handling for the above `DeleteOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while deleting the group."})
+			return
+		}
+		if result.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the group doesn't exist."})
+			return
+		}
+
+		// Removes the group from its member users and groups. Memberships left behind if this fails grant nothing,
+		// as the group no longer exists.
+		pull := bson.D{{Key: "$pull", Value: bson.D{{Key: "groups", Value: groupId}}}}
+		if _, err := userCollection.UpdateMany(ctx, bson.M{"groups": groupId}, pull); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while removing the group's members."})
+			return
+		}
+		if _, err := groupCollection.UpdateMany(ctx, bson.M{"groups": groupId}, pull); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while removing the group's members."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditGroupChange, Details: map[string]string{"action": "delete", "group_id": groupId}})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"deleted": groupId})
+	}
+}
+
+// Handler function for the `PUT /groups/:group_id/users/:user_id` route, which adds a user to a group.
+func AddGroupUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		groupId, userId := c.Param("group_id"), c.Param("user_id")
+		if !authorizeGroupRoles(ctx, c, groupId) {
+			return
+		}
+
+		result, err := userCollection.UpdateOne(ctx, bson.M{"userid": userId}, bson.D{
+			{Key: "$addToSet", Value: bson.D{{Key: "groups", Value: groupId}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while adding the user to the group."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the user doesn't exist."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditGroupChange,
+			TargetID: userId,
+			Details:  map[string]string{"action": "add_user", "group_id": groupId},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"added": userId})
+	}
+}
+
+// Handler function for the `DELETE /groups/:group_id/users/:user_id` route, which removes a user from a group.
+func RemoveGroupUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		groupId, userId := c.Param("group_id"), c.Param("user_id")
+		result, err := userCollection.UpdateOne(ctx, bson.M{"userid": userId, "groups": groupId}, bson.D{
+			{Key: "$pull", Value: bson.D{{Key: "groups", Value: groupId}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while removing the user from the group."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the user isn't a member of this group."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditGroupChange,
+			TargetID: userId,
+			Details:  map[string]string{"action": "remove_user", "group_id": groupId},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"removed": userId})
+	}
+}
+
+// Handler function for the `PUT /groups/:group_id/groups/:child_id` route, which nests the group `child_id` in the
+// group `group_id`, refusing to create a cycle.
+func AddGroupChild() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		groupId, childId := c.Param("group_id"), c.Param("child_id")
+		if !authorizeGroupRoles(ctx, c, groupId) {
+			return
+		}
+
+		groupNestingMutex.Lock()
+		defer groupNestingMutex.Unlock()
+
+		// The child can't be the group itself or any group it is already, directly or not, a member of.
+		ancestors, err := helpers.GroupAncestors(ctx, []string{groupId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking the group nesting."})
+			return
+		}
+		if path, cycle := ancestors[childId]; cycle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "adding this group would create a cycle.", "cycle": append(path, groupId)})
+			return
+		}
+
+		result, err := groupCollection.UpdateOne(ctx, bson.M{"groupid": childId}, bson.D{
+			{Key: "$addToSet", Value: bson.D{{Key: "groups", Value: groupId}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now().UTC()}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while nesting the group."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the group doesn't exist."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditGroupChange,
+			Details: map[string]string{"action": "add_group", "group_id": groupId, "child_id": childId},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"added": childId})
+	}
+}
+
+// Handler function for the `DELETE /groups/:group_id/groups/:child_id` route, which takes the group `child_id` out of
+// the group `group_id`.
+func RemoveGroupChild() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		groupId, childId := c.Param("group_id"), c.Param("child_id")
+		result, err := groupCollection.UpdateOne(ctx, bson.M{"groupid": childId, "groups": groupId}, bson.D{
+			{Key: "$pull", Value: bson.D{{Key: "groups", Value: groupId}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now().UTC()}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while removing the group."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the group isn't a member of this group."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditGroupChange,
+			Details: map[string]string{"action": "remove_group", "group_id": groupId, "child_id": childId},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"removed": childId})
+	}
+}
+
+// Checks the group `groupID` exists and that the caller holds every permission its members would inherit, from it
+// and the groups it is nested in. Otherwise it responds with an error and returns false.
+func authorizeGroupRoles(ctx context.Context, c *gin.Context, groupID string) bool {
+	count, err := groupCollection.CountDocuments(ctx, bson.M{"groupid": groupID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while finding the group."})
+		return false
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the group doesn't exist."})
+		return false
+	}
+
+	roles, err := helpers.GroupRoles(ctx, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while finding the group's roles."})
+		return false
+	}
+
+	return authorizeGrantedRoles(ctx, c, roles, "")
+}
@@ -0,0 +1,434 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creates `invitationCollection` variable that uses the `invitation` collection from MongoDB instance.
+var invitationCollection *mongo.Collection = database.OpenCollection(database.Client, "invitation")
+
+// Body of the `/orgs/:org_id/invitations` request.
+type invitationRequest struct {
+	Email string   `json:"email" validate:"required,email"`
+	Roles []string `json:"roles" validate:"dive,required"`
+}
+
+// Body of the `/invitations/accept` request. The account details are only needed when the invited email doesn't
+// have an account yet.
+type acceptInvitationRequest struct {
+	Token     string  `json:"token" validate:"required"`
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Password  *string `json:"password"`
+	Phone     *string `json:"phone_number"`
+}
+
+// Handler function for the `POST /orgs/:org_id/invitations` route, which invites an email address to join an
+// organization with pre-set roles. The admins of the organization can invite anyone, as the invitee has to accept.
+func CreateInvitation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request invitationRequest
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+		if request.Roles == nil {
+			request.Roles = []string{}
+		}
+
+		orgId := c.Param("org_id")
+		organization, ok := authorizeInvitations(ctx, c, orgId)
+		if !ok {
+			return
+		}
+
+		// Only existing roles whose permissions are all held by the caller within the organization can be given.
+		if !authorizeGrantedRoles(ctx, c, request.Roles, orgId) {
+			return
+		}
+
+		tokenId, err := helpers.GenerateRandomToken(16)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating the invitation."})
+			return
+		}
+		now := time.Now().UTC()
+		invitation := models.Invitation{
+			ID:        primitive.NewObjectID(),
+			OrgID:     orgId,
+			Email:     request.Email,
+			Roles:     request.Roles,
+			Status:    models.InvitationPending,
+			InvitedBy: c.GetString("user_id"),
+			TokenID:   tokenId,
+			SentCount: 1,
+			ExpiresAt: now.Add(time.Hour * time.Duration(helpers.INVITATION_EXPIRY_HOURS)),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		invitation.InvitationID = invitation.ID.Hex()
+
+		_, err = invitationCollection.InsertOne(ctx, invitation)
+		// Error handling for the above `InsertOne()` function.
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this email already has a pending invitation, resend it instead."})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating the invitation."})
+			return
+		}
+
+		if err := helpers.SendInvitationEmail(invitation, organization); err != nil {
+			log.Println(err)
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditOrgInvitation,
+			Details: map[string]string{"action": "create", "org_id": orgId, "invitation_id": invitation.InvitationID, "email": invitation.Email, "roles": strings.Join(invitation.Roles, " ")},
+		})
+
+		// Returns a code 201 status and the invitation.
+		c.JSON(http.StatusCreated, invitation)
+	}
+}
+
+// Handler function for the `GET /orgs/:org_id/invitations` route, which can be filtered by the `status` query parameter.
+func GetInvitations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orgId := c.Param("org_id")
+		if _, ok := authorizeInvitations(ctx, c, orgId); !ok {
+			return
+		}
+
+		// Expired invitations are stored as pending, so the two are told apart by their expiry.
+		now := time.Now()
+		filter := bson.M{"orgid": orgId}
+		switch status := c.Query("status"); status {
+		case "":
+		case models.InvitationPending:
+			filter["status"] = models.InvitationPending
+			filter["expiresat"] = bson.M{"$gt": now}
+		case models.InvitationExpired:
+			filter["status"] = models.InvitationPending
+			filter["expiresat"] = bson.M{"$lte": now}
+		case models.InvitationAccepted, models.InvitationRevoked:
+			filter["status"] = status
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the status " + status + " is invalid."})
+			return
+		}
+
+		cursor, err := invitationCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}))
+		// Error handling for the above `Find()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing invitations."})
+			return
+		}
+
+		invitations := []models.Invitation{}
+		if err = cursor.All(ctx, &invitations); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing invitations."})
+			return
+		}
+		for index := range invitations {
+			if invitations[index].Status == models.InvitationPending && !invitations[index].ExpiresAt.After(now) {
+				invitations[index].Status = models.InvitationExpired
+			}
+		}
+
+		// Returns a code 200 status and the invitations.
+		c.JSON(http.StatusOK, invitations)
+	}
+}
+
+// Handler function for the `POST /orgs/:org_id/invitations/:invitation_id/resend` route, which emails a new link for a
+// pending invitation, even an expired one, and stops the previous links from working.
+func ResendInvitation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var invitation models.Invitation
+		defer cancel()
+
+		orgId := c.Param("org_id")
+		organization, ok := authorizeInvitations(ctx, c, orgId)
+		if !ok {
+			return
+		}
+
+		tokenId, err := helpers.GenerateRandomToken(16)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while resending the invitation."})
+			return
+		}
+		now := time.Now().UTC()
+		err = invitationCollection.FindOneAndUpdate(ctx,
+			bson.M{"invitationid": c.Param("invitation_id"), "orgid": orgId, "status": models.InvitationPending},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "tokenid", Value: tokenId},
+					{Key: "expiresat", Value: now.Add(time.Hour * time.Duration(helpers.INVITATION_EXPIRY_HOURS))},
+					{Key: "updatedat", Value: now},
+				}},
+				{Key: "$inc", Value: bson.D{{Key: "sentcount", Value: 1}}},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&invitation)
+		// Error handling for the above `FindOneAndUpdate()` function.
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the invitation doesn't exist or isn't pending."})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while resending the invitation."})
+			return
+		}
+
+		if err := helpers.SendInvitationEmail(invitation, organization); err != nil {
+			log.Println(err)
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditOrgInvitation,
+			Details: map[string]string{"action": "resend", "org_id": orgId, "invitation_id": invitation.InvitationID},
+		})
+
+		// Returns a code 200 status and the invitation.
+		c.JSON(http.StatusOK, invitation)
+	}
+}
+
+// Handler function for the `DELETE /orgs/:org_id/invitations/:invitation_id` route, which revokes a pending invitation.
+func RevokeInvitation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orgId := c.Param("org_id")
+		if _, ok := authorizeInvitations(ctx, c, orgId); !ok {
+			return
+		}
+
+		result, err := invitationCollection.UpdateOne(ctx,
+			bson.M{"invitationid": c.Param("invitation_id"), "orgid": orgId, "status": models.InvitationPending},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: models.InvitationRevoked}, {Key: "updatedat", Value: time.Now().UTC()}}}},
+		)
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while revoking the invitation."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the invitation doesn't exist or isn't pending."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditOrgInvitation,
+			Details: map[string]string{"action": "revoke", "org_id": orgId, "invitation_id": c.Param("invitation_id")},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"revoked": c.Param("invitation_id")})
+	}
+}
+
+// Handler function for the `/invitations/accept` route. The invitation joins the account the invited email already
+// has in the organization, or creates one with the provided details. Holding the emailed link proves the invitee
+// controls the address, but the user still has to sign in afterwards, so MFA is never bypassed.
+func AcceptInvitation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request acceptInvitationRequest
+		var invitation models.Invitation
+		var user models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Validates the signature, audience and expiry of the link, which must be the last one sent for a pending invitation.
+		claims, msg := helpers.ValidateInvitationToken(request.Token)
+		if msg == "" {
+			err := invitationCollection.FindOne(ctx, bson.M{
+				"invitationid": claims.Subject,
+				"tokenid":      claims.Id,
+				"status":       models.InvitationPending,
+				"expiresat":    bson.M{"$gt": time.Now()},
+			}).Decode(&invitation)
+			if err != nil {
+				msg = "the invitation is invalid"
+			}
+		}
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the invitation link is invalid or expired."})
+			return
+		}
+		organization, err := helpers.FindOrganization(ctx, invitation.OrgID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the organization doesn't exist anymore."})
+			return
+		}
+
+		// Finds the account the invited email already has among the accounts of the organization.
+		err = userCollection.FindOne(ctx, helpers.EmailFilter(invitation.Email, helpers.EmailTenant(organization))).Decode(&user)
+		created := err == mongo.ErrNoDocuments
+		if err != nil && !created {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while finding the account."})
+			return
+		}
+
+		// Prepares the new account exactly like a sign up would, with the email the invitation was sent to.
+		if created {
+			userType := models.RoleUser
+			now := time.Now()
+			user = models.User{
+				FirstName:         request.FirstName,
+				LastName:          request.LastName,
+				Password:          request.Password,
+				Email:             &invitation.Email,
+				Phone:             request.Phone,
+				UserType:          &userType,
+				Roles:             []string{},
+				Groups:            []string{},
+				TenantID:          helpers.EmailTenant(organization),
+				Memberships:       []models.OrgMembership{{OrgID: organization.OrgID, Roles: invitation.Roles, JoinedAt: now}},
+				PasswordHistory:   []string{},
+				PasswordChangedAt: now,
+				CreatedAt:         now,
+				UpdatedAt:         now,
+			}
+			if validationError := validate.Struct(user); validationError != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+				return
+			}
+			if err := helpers.CurrentPasswordPolicy.Validate(*user.Password, passwordPersonalInfo(user)...); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			countPhone, err := userCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking user phone number."})
+				return
+			}
+			if countPhone > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "the phone number provided is already in use."})
+				return
+			}
+
+			password, err := HashPassword(*user.Password)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			user.Password = &password
+			user.ID = primitive.NewObjectID()
+			user.UserID = user.ID.Hex()
+		}
+
+		// Marks the invitation accepted first, which only succeeds once, so the link can't be used again.
+		result, err := invitationCollection.UpdateOne(ctx,
+			bson.M{"invitationid": invitation.InvitationID, "tokenid": invitation.TokenID, "status": models.InvitationPending},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: models.InvitationAccepted},
+				{Key: "acceptedby", Value: user.UserID},
+				{Key: "updatedat", Value: time.Now().UTC()},
+			}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while accepting the invitation."})
+			return
+		}
+		if result.ModifiedCount == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the invitation link is invalid or expired."})
+			return
+		}
+
+		if created {
+			_, err = userCollection.InsertOne(ctx, user)
+		} else if _, member := helpers.FindMembership(user, organization.OrgID); member {
+			// Members keep their roles and gain the ones of the invitation.
+			_, err = userCollection.UpdateOne(ctx, bson.M{"userid": user.UserID, "memberships.orgid": organization.OrgID}, bson.D{
+				{Key: "$addToSet", Value: bson.D{{Key: "memberships.$.roles", Value: bson.D{{Key: "$each", Value: invitation.Roles}}}}},
+				{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+			})
+		} else {
+			membership := models.OrgMembership{OrgID: organization.OrgID, Roles: invitation.Roles, JoinedAt: time.Now()}
+			_, err = userCollection.UpdateOne(ctx, bson.M{"userid": user.UserID, "memberships.orgid": bson.M{"$ne": organization.OrgID}}, bson.D{
+				{Key: "$push", Value: bson.D{{Key: "memberships", Value: membership}}},
+				{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+			})
+		}
+		// Error handling for the above `InsertOne()` and `UpdateOne()` functions.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while joining the organization."})
+			return
+		}
+
+		if created {
+			helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditSignUp, ActorID: user.UserID, TargetID: user.UserID, Details: map[string]string{"invitation_id": invitation.InvitationID}})
+		}
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditOrgInvitation,
+			ActorID:  user.UserID,
+			TargetID: user.UserID,
+			Details:  map[string]string{"action": "accept", "org_id": organization.OrgID, "invitation_id": invitation.InvitationID},
+		})
+
+		// Returns a code 200 status, the user then signs in to the organization as usual.
+		c.JSON(http.StatusOK, gin.H{"org_id": organization.OrgID, "user_id": user.UserID, "created": created})
+	}
+}
+
+// Checks the organization `orgID` exists and that the caller may manage its members, which its admins may. Otherwise it
+// responds with an error and returns false.
+func authorizeInvitations(ctx context.Context, c *gin.Context, orgID string) (models.Organization, bool) {
+	if err := helpers.Authorize(c, models.PermissionOrgsMembers, models.PolicyResource{Type: "organization", ID: orgID, Tenant: orgID}); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return models.Organization{}, false
+	}
+
+	organization, err := helpers.FindOrganization(ctx, orgID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the organization doesn't exist."})
+		return organization, false
+	}
+
+	return organization, true
+}
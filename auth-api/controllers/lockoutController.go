@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Handler function for the `/users/:user_id/unlock` route, which lets users granted `users:write` unlock a locked account.
+func UnlockUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// Clears the lockout and every failed login counter of the user.
+		result, err := userCollection.UpdateOne(ctx, bson.M{"userid": c.Param("user_id")}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "failedloginattempts", Value: 0},
+				{Key: "lockoutcount", Value: 0},
+				{Key: "lockeduntil", Value: time.Time{}},
+			}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while unlocking the user."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditAccountUnlock, TargetID: c.Param("user_id")})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"unlocked": true})
+	}
+}
+
+// Records a failed password or MFA code check for `user`, locking the account for an escalating duration once
+// `LOCKOUT_THRESHOLD` consecutive checks have failed. Attempts on an account that is already `locked` aren't
+// counted, but make the same update, so that the response doesn't reveal the lock by taking less time.
+func recordFailedLogin(ctx context.Context, user models.User, locked bool) {
+	var updated models.User
+
+	increment := 1
+	if locked {
+		increment = 0
+	}
+
+	// Atomically counts the failure and returns the updated user.
+	err := userCollection.FindOneAndUpdate(ctx,
+		bson.M{"userid": user.UserID},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "failedloginattempts", Value: increment}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if locked || updated.FailedLoginAttempts < helpers.LOCKOUT_THRESHOLD {
+		return
+	}
+
+	// Locks the account, only the first concurrent request over the threshold gets to do so.
+	duration := helpers.LockoutDuration(updated.LockoutCount)
+	lockedUntil := time.Now().Add(duration)
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"userid": user.UserID, "failedloginattempts": bson.M{"$gte": helpers.LOCKOUT_THRESHOLD}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "failedloginattempts", Value: 0},
+				{Key: "lockeduntil", Value: lockedUntil},
+			}},
+			{Key: "$inc", Value: bson.D{{Key: "lockoutcount", Value: 1}}},
+		},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		return
+	}
+
+	// Lets the user know, since it may mean someone is trying to guess their password. The email is sent in the
+	// background, so that the request locking the account doesn't take longer than the others.
+	body := fmt.Sprintf("Your account was locked for %s after %d failed sign-in attempts.\n\nIf this wasn't you, someone may be trying to guess your password. Consider changing it once the lock expires.", duration, helpers.LOCKOUT_THRESHOLD)
+	go sendSecurityEmail(*user.Email, "Your account has been locked", body)
+}
+
+// Clears the failed login counters of `user` after a successful password check.
+func resetFailedLogins(ctx context.Context, user models.User) {
+	if user.FailedLoginAttempts == 0 && user.LockoutCount == 0 {
+		return
+	}
+
+	_, err := userCollection.UpdateOne(ctx, bson.M{"userid": user.UserID}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "failedloginattempts", Value: 0},
+			{Key: "lockoutcount", Value: 0},
+		}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
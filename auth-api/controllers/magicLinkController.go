@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Creates `magicLinkCollection` variable that uses the `magic_link` collection from MongoDB instance, where unused
+// links are deleted once expired.
+var magicLinkCollection *mongo.Collection = helpers.OpenMagicLinkCollection()
+
+// Name of the cookie that binds a magic link to the device it was requested from.
+const magicLinkDeviceCookie = "magic_link_device"
+
+// Body of the `/users/login/magic-link` request.
+type magicLinkRequest struct {
+	Email      string `json:"email" validate:"required,email"`
+	BindIP     bool   `json:"bind_ip"`
+	BindDevice bool   `json:"bind_device"`
+}
+
+// Handler function for the `/users/login/magic-link` route.
+func RequestMagicLink() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request magicLinkRequest
+		var foundUser models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Binds the link to the requesting IP address, if asked to.
+		ipHash := ""
+		if request.BindIP {
+			ipHash = helpers.HashToken(c.ClientIP())
+		}
+
+		// Binds the link to the requesting device through a cookie, if asked to. The cookie is set whether
+		// or not the email exists, so the response doesn't reveal which emails are registered.
+		deviceHash := ""
+		if request.BindDevice {
+			device, err := helpers.GenerateRandomToken(32)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the link."})
+				return
+			}
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(magicLinkDeviceCookie, device, helpers.MagicLinkMinutes*60, "/", "", c.Request.TLS != nil, true)
+			deviceHash = helpers.HashToken(device)
+		}
+
+		// The same response is returned whether or not the email belongs to a user.
+		response := gin.H{"message": "if the email is registered, a sign-in link has been sent."}
+
+		// Finds the user the link is requested for, in the organization of the `tenant_id` query parameter if it is present.
+		filter, err := helpers.AccountFilter(ctx, request.Email, c.Query("tenant_id"))
+		if err == nil {
+			err = userCollection.FindOne(ctx, filter).Decode(&foundUser)
+		}
+		if err != nil {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		// Generates the signed link token, identified by a random ID that is stored until it is redeemed.
+		tokenId, err := helpers.GenerateRandomToken(16)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the link."})
+			return
+		}
+		token, err := helpers.GenerateMagicLinkToken(foundUser.UserID, tokenId, ipHash, deviceHash)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the link."})
+			return
+		}
+
+		_, err = magicLinkCollection.InsertOne(ctx, models.MagicLink{
+			ID:        primitive.NewObjectID(),
+			TokenID:   tokenId,
+			UserID:    foundUser.UserID,
+			ExpiresAt: time.Now().Add(time.Minute * time.Duration(helpers.MagicLinkMinutes)),
+		})
+		// Error handling for the above `InsertOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the link."})
+			return
+		}
+
+		// Emails the link to the user, which signs in to the same organization. It is sent in the background, so that the
+		// response takes as long whether or not the email belongs to a user.
+		link := fmt.Sprintf("%s?token=%s", helpers.MAGIC_LINK_URL, token)
+		if tenant := c.Query("tenant_id"); tenant != "" {
+			link += "&tenant_id=" + url.QueryEscape(tenant)
+		}
+		body := fmt.Sprintf("Use the link below to sign in. It expires in %d minutes and can only be used once.\n\n%s\n\nIf you didn't request this, you can ignore this email.", helpers.MagicLinkMinutes, link)
+		go sendSecurityEmail(*foundUser.Email, "Your sign-in link", body)
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// Handler function for the `/users/login/magic-link/callback` route.
+func MagicLinkCallback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var magicLink models.MagicLink
+		var foundUser models.User
+		defer cancel()
+
+		// Validates the signature, audience and expiry of the link token.
+		claims, msg := helpers.ValidateMagicLinkToken(c.Query("token"))
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the sign-in link is invalid or expired."})
+			return
+		}
+
+		// Checks the IP address and device bindings of the link.
+		if claims.IPHash != "" && claims.IPHash != helpers.HashToken(c.ClientIP()) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the sign-in link must be opened from the network it was requested from."})
+			return
+		}
+		if claims.DeviceHash != "" {
+			device, err := c.Cookie(magicLinkDeviceCookie)
+			if err != nil || claims.DeviceHash != helpers.HashToken(device) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "the sign-in link must be opened on the device it was requested from."})
+				return
+			}
+		}
+
+		// Deletes the stored link, which only succeeds once, so the link can't be replayed.
+		err := magicLinkCollection.FindOneAndDelete(ctx, bson.M{
+			"tokenid":   claims.Id,
+			"userid":    claims.Subject,
+			"expiresat": bson.M{"$gt": time.Now()},
+		}).Decode(&magicLink)
+		if err != nil {
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:     models.AuditLoginFailure,
+				Outcome:  models.AuditOutcomeFailure,
+				TargetID: claims.Subject,
+				Details:  map[string]string{"method": "magic_link", "reason": "link_already_used"},
+			})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the sign-in link is invalid or expired."})
+			return
+		}
+
+		// Finds the user the link was issued for.
+		if err := userCollection.FindOne(ctx, bson.M{"userid": magicLink.UserID}).Decode(&foundUser); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the sign-in link is invalid or expired."})
+			return
+		}
+
+		// Clears the device cookie now that it has served its purpose.
+		if claims.DeviceHash != "" {
+			c.SetCookie(magicLinkDeviceCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+		}
+
+		respondWithLogin(c, foundUser)
+	}
+}
@@ -0,0 +1,373 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Creates `mfaChallengeCollection` variable that uses the `mfa_challenge` collection from MongoDB instance, where
+// challenges that were never completed are deleted once expired.
+var mfaChallengeCollection *mongo.Collection = helpers.OpenMFAChallengeCollection()
+
+// Body of the requests that submit a TOTP code.
+type mfaCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// Body of the `/users/login/mfa` request, which takes either a TOTP code or a recovery code.
+type mfaLoginRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+// Handler function for the `/users/mfa/totp/enroll` route.
+func EnrollTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		// Initiates the `user` variable which stores the `User` model of the authenticated user.
+		var user models.User
+		defer cancel()
+
+		// Finds the authenticated user using the `user_id` set by the `Authenticate()` middleware.
+		if err := userCollection.FindOne(ctx, bson.M{"userid": c.GetString("user_id")}).Decode(&user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// A second authenticator can't be enrolled on top of a confirmed one.
+		if user.MFAEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is already enabled."})
+			return
+		}
+
+		// Generates a new secret for the authenticator app.
+		secret, err := helpers.GenerateTOTPSecret()
+		// Error handling for the above function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the mfa secret."})
+			return
+		}
+
+		// Builds the `otpauth://` URI and its QR code so the secret can be scanned.
+		uri := helpers.TOTPURI(secret, *user.Email)
+		png, err := helpers.TOTPQRCode(uri)
+		// Error handling for the above function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the qr code."})
+			return
+		}
+
+		// Stores the secret as pending until the first code is confirmed.
+		_, err = userCollection.UpdateOne(ctx, bson.M{"userid": user.UserID}, bson.D{
+			{Key: "$set", Value: bson.D{{Key: "mfapendingsecret", Value: secret}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while storing the mfa secret."})
+			return
+		}
+
+		// Returns a code 200 status, the secret, its URI and the base64 encoded QR code PNG.
+		c.JSON(http.StatusOK, gin.H{
+			"secret":      secret,
+			"otpauth_uri": uri,
+			"qr_code":     base64.StdEncoding.EncodeToString(png),
+		})
+	}
+}
+
+// Handler function for the `/users/mfa/totp/confirm` route.
+func ConfirmTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request mfaCodeRequest
+		var user models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Finds the authenticated user using the `user_id` set by the `Authenticate()` middleware.
+		if err := userCollection.FindOne(ctx, bson.M{"userid": c.GetString("user_id")}).Decode(&user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if user.MFAPendingSecret == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no mfa enrollment is pending."})
+			return
+		}
+
+		// Checks that the authenticator app produces the same codes as the pending secret.
+		step, ok := helpers.ValidateTOTP(*user.MFAPendingSecret, request.Code, time.Now(), 0)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the mfa code is incorrect."})
+			return
+		}
+
+		// Generates the recovery codes the user can fall back on if they lose their authenticator.
+		recoveryCodes, recoveryHashes, err := helpers.GenerateRecoveryCodes()
+		// Error handling for the above function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating recovery codes."})
+			return
+		}
+
+		// Promotes the pending secret and enables MFA for the user.
+		_, err = userCollection.UpdateOne(ctx, bson.M{"userid": user.UserID}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "mfaenabled", Value: true},
+				{Key: "mfasecret", Value: *user.MFAPendingSecret},
+				{Key: "mfalastusedstep", Value: step},
+				{Key: "mfarecoverycodes", Value: recoveryHashes},
+			}},
+			{Key: "$unset", Value: bson.D{{Key: "mfapendingsecret", Value: ""}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while enabling mfa."})
+			return
+		}
+
+		// Returns a code 200 status and the recovery codes, which are only ever shown this once.
+		c.JSON(http.StatusOK, gin.H{"mfa_enabled": true, "recovery_codes": recoveryCodes})
+	}
+}
+
+// Handler function for the `/users/login/mfa` route.
+func LoginMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request mfaLoginRequest
+		var foundUser models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Validates the challenge token handed out by `Login()`.
+		userId, challengeId, msg := helpers.ValidateMFAChallengeToken(request.MFAToken)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		// Counts the attempt against the challenge, which can't be used anymore once it ran out of attempts.
+		if err := countMFAChallengeAttempt(ctx, challengeId, userId); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the mfa challenge has expired, log in again."})
+			return
+		}
+
+		// Finds the user the challenge was issued for.
+		err := userCollection.FindOne(ctx, bson.M{"userid": userId}).Decode(&foundUser)
+		if err != nil || !foundUser.MFAEnabled || foundUser.MFASecret == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the mfa code is incorrect."})
+			return
+		}
+
+		// Checks the recovery code or the TOTP code, whichever was provided. Accounts locked by failed passwords or codes
+		// get the same response as a wrong code.
+		locked := foundUser.LockedUntil.After(time.Now())
+		var ok bool
+		if !locked {
+			if request.RecoveryCode != "" {
+				ok = consumeRecoveryCode(ctx, &foundUser, request.RecoveryCode)
+			} else {
+				ok = consumeTOTPCode(ctx, foundUser, request.Code)
+			}
+		}
+		if !ok {
+			reason := "locked"
+			if !locked {
+				reason = "invalid_mfa_code"
+			}
+			// Wrong codes count towards the lockout like wrong passwords.
+			recordFailedLogin(ctx, foundUser, locked)
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:     models.AuditLoginFailure,
+				Outcome:  models.AuditOutcomeFailure,
+				TargetID: foundUser.UserID,
+				Details:  map[string]string{"method": "mfa", "reason": reason},
+			})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the mfa code is incorrect."})
+			return
+		}
+
+		// Completes the challenge, which only succeeds once, so the challenge token can't be used to log in again.
+		result, err := mfaChallengeCollection.DeleteOne(ctx, bson.M{"challengeid": challengeId})
+		if err != nil || result.DeletedCount != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the mfa challenge has expired, log in again."})
+			return
+		}
+
+		// Clears the failed login counters now that both factors were right.
+		resetFailedLogins(ctx, foundUser)
+
+		respondWithTokens(c, foundUser)
+	}
+}
+
+// Handler function for the `/users/mfa/recovery-codes` route, which replaces all recovery codes of the user.
+func RegenerateRecoveryCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request mfaCodeRequest
+		var user models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Finds the authenticated user using the `user_id` set by the `Authenticate()` middleware.
+		if err := userCollection.FindOne(ctx, bson.M{"userid": c.GetString("user_id")}).Decode(&user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !user.MFAEnabled || user.MFASecret == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is not enabled."})
+			return
+		}
+
+		// Requires a current TOTP code so a stolen access token alone can't mint new recovery codes.
+		if !consumeTOTPCode(ctx, user, request.Code) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the mfa code is incorrect."})
+			return
+		}
+
+		// Generates the new recovery codes.
+		recoveryCodes, recoveryHashes, err := helpers.GenerateRecoveryCodes()
+		// Error handling for the above function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating recovery codes."})
+			return
+		}
+
+		// Replaces the stored hashes, invalidating every previous code.
+		_, err = userCollection.UpdateOne(ctx, bson.M{"userid": user.UserID}, bson.D{
+			{Key: "$set", Value: bson.D{{Key: "mfarecoverycodes", Value: recoveryHashes}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while storing recovery codes."})
+			return
+		}
+
+		// Returns a code 200 status and the new recovery codes.
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+	}
+}
+
+// Checks `code` against the TOTP secret of `user` and records its time step so it can't be used again.
+func consumeTOTPCode(ctx context.Context, user models.User, code string) bool {
+	// Checks the code against the user's secret, ignoring time steps that were already used.
+	step, ok := helpers.ValidateTOTP(*user.MFASecret, code, time.Now(), user.MFALastUsedStep)
+	if !ok {
+		return false
+	}
+
+	// Records the used time step, only if no concurrent request has already used it or a later one.
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"userid": user.UserID, "mfalastusedstep": bson.M{"$lt": step}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "mfalastusedstep", Value: step}}}},
+	)
+
+	return err == nil && result.ModifiedCount == 1
+}
+
+// Removes the recovery code `code` from `user`, returning whether it was a valid unused code.
+func consumeRecoveryCode(ctx context.Context, user *models.User, code string) bool {
+	hash := helpers.HashRecoveryCode(code)
+
+	// Pulling the hash only succeeds once, so concurrent requests can't both use the same code.
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"userid": user.UserID, "mfarecoverycodes": hash},
+		bson.D{{Key: "$pull", Value: bson.D{{Key: "mfarecoverycodes", Value: hash}}}},
+	)
+	if err != nil || result.ModifiedCount != 1 {
+		return false
+	}
+
+	// Keeps the in-memory user in line with the stored one.
+	remaining := []string{}
+	for _, stored := range user.MFARecoveryCodes {
+		if stored != hash {
+			remaining = append(remaining, stored)
+		}
+	}
+	user.MFARecoveryCodes = remaining
+
+	return true
+}
+
+// Stores a new MFA challenge for `userId` and returns the challenge token identifying it.
+func storeMFAChallenge(userId string) (string, error) {
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	challengeId, err := helpers.GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = mfaChallengeCollection.InsertOne(ctx, models.MFAChallenge{
+		ID:          primitive.NewObjectID(),
+		ChallengeID: challengeId,
+		UserID:      userId,
+		Attempts:    0,
+		ExpiresAt:   time.Now().Add(time.Minute * time.Duration(helpers.MFAChallengeMinutes)),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return helpers.GenerateMFAChallengeToken(userId, challengeId)
+}
+
+// Counts a code submitted for the challenge `challengeId` of `userId`, failing once `MFAChallengeMaxAttempts` codes
+// were submitted for it or if it was already completed.
+func countMFAChallengeAttempt(ctx context.Context, challengeId, userId string) error {
+	return mfaChallengeCollection.FindOneAndUpdate(ctx,
+		bson.M{
+			"challengeid": challengeId,
+			"userid":      userId,
+			"attempts":    bson.M{"$lt": helpers.MFAChallengeMaxAttempts},
+			"expiresat":   bson.M{"$gt": time.Now()},
+		},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}}},
+	).Err()
+}
@@ -0,0 +1,599 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creates `oauthClientCollection` variable that uses the `oauth_client` collection from MongoDB instance.
+var oauthClientCollection *mongo.Collection = database.OpenCollection(database.Client, "oauth_client")
+
+// Body of the `POST /oauth/clients` request.
+type oauthClientRequest struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+	Type string `json:"type" validate:"required,eq=public|eq=confidential"`
+	// Grants the client may use, defaulting to the authorization code grant.
+	GrantTypes []string `json:"grant_types" validate:"dive,eq=authorization_code|eq=client_credentials|eq=urn:ietf:params:oauth:grant-type:device_code"`
+	// How a confidential client authenticates at the token endpoint, defaulting to its secret.
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method" validate:"omitempty,eq=client_secret_basic|eq=private_key_jwt"`
+	PublicKey               string   `json:"public_key"`
+	RedirectURIs            []string `json:"redirect_uris" validate:"max=10,dive,required"`
+	Scopes                  []string `json:"scopes" validate:"required,min=1,dive,required"`
+	FirstParty              bool     `json:"first_party"`
+}
+
+// Parameters of the `/oauth/authorize` request, given in the query of the `GET` request and the body of the `POST` one.
+type authorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	// OpenID Connect nonce the ID token must repeat.
+	Nonce string `form:"nonce" json:"nonce"`
+	// Whether the user allowed the client to act on its behalf, only read from the `POST` request.
+	Approve bool `form:"-" json:"approve"`
+}
+
+// Handler function for the `POST /oauth/clients` route, which registers an application. The secret of a confidential
+// client is only ever returned in this response.
+func CreateOAuthClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request oauthClientRequest
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+		if len(request.GrantTypes) == 0 {
+			request.GrantTypes = []string{models.OAuthGrantAuthorizationCode}
+		}
+		if request.RedirectURIs == nil {
+			request.RedirectURIs = []string{}
+		}
+
+		// Users are sent back with authorization codes to one of the redirect URIs, and only confidential clients can
+		// act on their own behalf.
+		authorizationCode := helpers.OAuthClientAllowsGrant(models.OAuthClient{GrantTypes: request.GrantTypes}, models.OAuthGrantAuthorizationCode)
+		if authorizationCode && len(request.RedirectURIs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "clients using authorization codes need a redirect URI."})
+			return
+		}
+		clientCredentials := helpers.OAuthClientAllowsGrant(models.OAuthClient{GrantTypes: request.GrantTypes}, models.OAuthGrantClientCredentials)
+		if clientCredentials && request.Type != models.OAuthClientConfidential {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only confidential clients can use client credentials."})
+			return
+		}
+		for _, redirectURI := range request.RedirectURIs {
+			if err := helpers.ValidateRedirectURI(redirectURI); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// Only our own applications can manage the accounts of their users.
+		scopes, err := helpers.ParseScope(strings.Join(request.Scopes, " "))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !request.FirstParty && helpers.ScopeCovers(scopes, []string{models.ScopeAccount}) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only first-party clients can be allowed " + models.ScopeAccount + "."})
+			return
+		}
+
+		// Public clients can't authenticate, while confidential ones use their secret or a key pair.
+		authMethod := request.TokenEndpointAuthMethod
+		switch {
+		case request.Type == models.OAuthClientPublic:
+			if authMethod != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "public clients can't authenticate."})
+				return
+			}
+			authMethod = models.OAuthAuthNone
+		case authMethod == models.OAuthAuthPrivateKeyJWT:
+			if _, err := helpers.ParseOAuthPublicKey(request.PublicKey); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		default:
+			authMethod = models.OAuthAuthClientSecret
+			request.PublicKey = ""
+		}
+
+		clientId, err := helpers.GenerateRandomToken(16)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the client ID."})
+			return
+		}
+		client := models.OAuthClient{
+			ID:                      primitive.NewObjectID(),
+			ClientID:                clientId,
+			Name:                    request.Name,
+			Type:                    request.Type,
+			GrantTypes:              request.GrantTypes,
+			TokenEndpointAuthMethod: authMethod,
+			RedirectURIs:            request.RedirectURIs,
+			Scopes:                  scopes,
+			FirstParty:              request.FirstParty,
+			PublicKey:               request.PublicKey,
+			CreatedBy:               c.GetString("user_id"),
+			CreatedAt:               time.Now().UTC(),
+		}
+
+		// Clients authenticating with a secret are given one, of which only the hash is stored.
+		response := gin.H{"client": &client}
+		if authMethod == models.OAuthAuthClientSecret {
+			secret, err := helpers.GenerateRandomToken(32)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the client secret."})
+				return
+			}
+			secret = "cs_" + secret
+			client.SecretHash = helpers.HashToken(secret)
+			response["client_secret"] = secret
+		}
+
+		if _, err := oauthClientCollection.InsertOne(ctx, client); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while registering the client."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditOAuthClientCreate,
+			TargetID: client.ClientID,
+			Details: map[string]string{
+				"name":        client.Name,
+				"type":        client.Type,
+				"grant_types": strings.Join(client.GrantTypes, " "),
+				"scope":       strings.Join(client.Scopes, " "),
+			},
+		})
+
+		// Returns a code 201 status and the client.
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// Handler function for the `GET /oauth/clients` route.
+func GetOAuthClients() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := oauthClientCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}}))
+		// Error handling for the above `Find()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing clients."})
+			return
+		}
+
+		clients := []models.OAuthClient{}
+		if err = cursor.All(ctx, &clients); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing clients."})
+			return
+		}
+
+		// Returns a code 200 status and the clients.
+		c.JSON(http.StatusOK, clients)
+	}
+}
+
+// Handler function for the `GET /oauth/authorize` route. It answers with the address to send the user back to the
+// client at, with an authorization code if the user already consented, and otherwise with what to ask consent for.
+func Authorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request authorizeRequest
+		defer cancel()
+
+		if err := c.ShouldBindQuery(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		client, redirectURI, scope, ok := checkAuthorizeRequest(ctx, c, request)
+		if !ok {
+			return
+		}
+
+		// First-party clients and clients the user already allowed these scopes don't need asking again.
+		scopes := strings.Fields(scope)
+		if !client.FirstParty && !helpers.HasOAuthConsent(ctx, c.GetString("user_id"), client.ClientID, scopes) {
+			c.JSON(http.StatusOK, gin.H{
+				"consent_required": true,
+				"client":           gin.H{"client_id": client.ClientID, "name": client.Name},
+				"scopes":           scopes,
+			})
+			return
+		}
+
+		issueAuthorizationCode(ctx, c, client, redirectURI, scope, request)
+	}
+}
+
+// Handler function for the `POST /oauth/authorize` route, which records the user's answer to the consent prompt.
+func ConsentAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request authorizeRequest
+		defer cancel()
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		client, redirectURI, scope, ok := checkAuthorizeRequest(ctx, c, request)
+		if !ok {
+			return
+		}
+
+		if !request.Approve {
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:     models.AuditOAuthConsent,
+				Outcome:  models.AuditOutcomeFailure,
+				TargetID: client.ClientID,
+				Details:  map[string]string{"scope": scope, "reason": "access_denied"},
+			})
+			authorizeError(c, redirectURI, request.State, "access_denied", "the user denied the request.")
+			return
+		}
+
+		if err := helpers.GrantOAuthConsent(ctx, c.GetString("user_id"), client.ClientID, strings.Fields(scope)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while recording the consent."})
+			return
+		}
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditOAuthConsent,
+			TargetID: client.ClientID,
+			Details:  map[string]string{"scope": scope},
+		})
+
+		issueAuthorizationCode(ctx, c, client, redirectURI, scope, request)
+	}
+}
+
+// Handler function for the `/oauth/token` route, which exchanges a grant for tokens. It reads form encoded requests
+// and answers errors the OAuth way.
+func OAuthToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// Tokens must never be cached along the way.
+		c.Header("Cache-Control", "no-store")
+		c.Header("Pragma", "no-cache")
+
+		switch c.PostForm("grant_type") {
+		case models.OAuthGrantAuthorizationCode:
+			exchangeAuthorizationCode(ctx, c)
+		case models.OAuthGrantClientCredentials:
+			exchangeClientCredentials(ctx, c)
+		case models.OAuthGrantDeviceCode:
+			exchangeDeviceCode(ctx, c)
+		case models.OAuthGrantRefreshToken:
+			exchangeRefreshToken(ctx, c)
+		default:
+			oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "the grant type isn't supported.")
+		}
+	}
+}
+
+// Checks an authorization `request` and returns the client it is for, the redirect URI to answer at and the scope to
+// grant. Errors are answered directly until the redirect URI is trusted, and sent back to the client afterwards.
+func checkAuthorizeRequest(ctx context.Context, c *gin.Context, request authorizeRequest) (client models.OAuthClient, redirectURI, scope string, ok bool) {
+	client, err := helpers.FindOAuthClient(ctx, request.ClientID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the client doesn't exist."})
+		return client, "", "", false
+	}
+	redirectURI, ok = helpers.OAuthRedirectURI(client, request.RedirectURI)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the redirect URI isn't registered for the client."})
+		return client, "", "", false
+	}
+
+	if !helpers.OAuthClientAllowsGrant(client, models.OAuthGrantAuthorizationCode) {
+		authorizeError(c, redirectURI, request.State, "unauthorized_client", "the client can't use authorization codes.")
+		return client, "", "", false
+	}
+	if request.ResponseType != "code" {
+		authorizeError(c, redirectURI, request.State, "unsupported_response_type", "only the code response type is supported.")
+		return client, "", "", false
+	}
+	// PKCE is mandatory, and the plain method would leak the verifier along with the code.
+	if request.CodeChallengeMethod != "S256" || len(request.CodeChallenge) != 43 {
+		authorizeError(c, redirectURI, request.State, "invalid_request", "a S256 code challenge is required.")
+		return client, "", "", false
+	}
+
+	// The scope defaults to every scope of the client, and can exceed neither them nor the scope of the user's token.
+	requested := request.Scope
+	if strings.TrimSpace(requested) == "" {
+		requested = strings.Join(client.Scopes, " ")
+	}
+	requestedScopes, err := helpers.ParseScope(requested)
+	if err != nil || !helpers.ScopeCovers(client.Scopes, requestedScopes) {
+		authorizeError(c, redirectURI, request.State, "invalid_scope", "the client isn't allowed the requested scope.")
+		return client, "", "", false
+	}
+	scope, err = helpers.NarrowScope(c.GetString("scope"), requested)
+	if err != nil {
+		authorizeError(c, redirectURI, request.State, "invalid_scope", "you can't grant the requested scope.")
+		return client, "", "", false
+	}
+
+	return client, redirectURI, scope, true
+}
+
+// Issues an authorization code for the authenticated user and answers with the address sending it to the client. The
+// code remembers the redirect URI as requested, which the token request must repeat.
+func issueAuthorizationCode(ctx context.Context, c *gin.Context, client models.OAuthClient, redirectURI, scope string, request authorizeRequest) {
+	code, err := helpers.CreateOAuthCode(ctx, models.OAuthCode{
+		ID:            primitive.NewObjectID(),
+		ClientID:      client.ClientID,
+		UserID:        c.GetString("user_id"),
+		RedirectURI:   request.RedirectURI,
+		Scope:         scope,
+		Tenant:        c.GetString("tenant"),
+		CodeChallenge: request.CodeChallenge,
+		Nonce:         request.Nonce,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while issuing the authorization code."})
+		return
+	}
+
+	// Returns a code 200 status and the address to send the user to.
+	c.JSON(http.StatusOK, gin.H{"redirect_to": helpers.OAuthRedirect(redirectURI, url.Values{"code": {code}, "state": {request.State}})})
+}
+
+// Answers an authorization request with the address sending the OAuth error `code` back to the client.
+func authorizeError(c *gin.Context, redirectURI, state, code, description string) {
+	c.JSON(http.StatusOK, gin.H{"redirect_to": helpers.OAuthRedirect(redirectURI, url.Values{
+		"error":             {code},
+		"error_description": {description},
+		"state":             {state},
+	})})
+}
+
+// Answers a token request with the OAuth error `code`.
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// Authenticates the client calling the token endpoint, with a signed `client_assertion`, HTTP Basic authentication
+// or the `client_id` and `client_secret` form fields. Public clients only identify themselves.
+func authenticateOAuthClient(ctx context.Context, c *gin.Context) (models.OAuthClient, bool) {
+	clientId, secret, basic := c.Request.BasicAuth()
+	assertion := c.PostForm("client_assertion")
+	if assertion != "" {
+		clientId = helpers.OAuthAssertionClientID(assertion)
+	} else if !basic {
+		clientId, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	// Clients must authenticate the way they were registered with.
+	client, err := helpers.FindOAuthClient(ctx, clientId)
+	if err == nil {
+		switch helpers.OAuthClientAuthMethod(client) {
+		case models.OAuthAuthPrivateKeyJWT:
+			if c.PostForm("client_assertion_type") != helpers.OAuthClientAssertionType || assertion == "" {
+				err = errors.New("the client must authenticate with an assertion")
+			} else {
+				err = helpers.VerifyClientAssertion(ctx, client, assertion)
+			}
+		case models.OAuthAuthClientSecret:
+			if assertion != "" || !helpers.CheckOAuthClientSecret(client, secret) {
+				err = errors.New("the client secret is invalid")
+			}
+		}
+	}
+	if err != nil {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "the client authentication failed.")
+		return client, false
+	}
+
+	return client, true
+}
+
+// Exchanges an authorization code, with the PKCE verifier it was issued for, for tokens.
+func exchangeAuthorizationCode(ctx context.Context, c *gin.Context) {
+	client, ok := authenticateOAuthClient(ctx, c)
+	if !ok {
+		return
+	}
+	if !helpers.OAuthClientAllowsGrant(client, models.OAuthGrantAuthorizationCode) {
+		oauthError(c, http.StatusBadRequest, "unauthorized_client", "the client can't use authorization codes.")
+		return
+	}
+
+	// Redeems the code first, so it can't be tried twice even when the rest of the request is wrong.
+	authorization, err := helpers.RedeemOAuthCode(ctx, c.PostForm("code"))
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "the authorization code is invalid or expired.")
+		return
+	}
+	if authorization.ClientID != client.ClientID || c.PostForm("redirect_uri") != authorization.RedirectURI {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "the authorization code was issued for another client or redirect URI.")
+		return
+	}
+	if !helpers.VerifyPKCE(c.PostForm("code_verifier"), authorization.CodeChallenge) {
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditOAuthToken,
+			Outcome:  models.AuditOutcomeFailure,
+			ActorID:  authorization.UserID,
+			TargetID: client.ClientID,
+			Details:  map[string]string{"grant_type": models.OAuthGrantAuthorizationCode, "reason": "invalid_code_verifier"},
+		})
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "the code verifier doesn't match the code challenge.")
+		return
+	}
+
+	issueUserTokens(ctx, c, client, authorization.UserID, authorization.Scope, authorization.Tenant, authorization.Nonce, models.OAuthGrantAuthorizationCode, "")
+}
+
+// Issues tokens acting on behalf of the user `userID`, who authorized `client` for `scope` within the organization
+// `tenant`, and an ID token repeating `nonce` if `scope` asks for one. The refresh token is stored on the client's
+// grant, replacing `previousRefreshToken` when it is being refreshed.
+func issueUserTokens(ctx context.Context, c *gin.Context, client models.OAuthClient, userID, scope, tenant, nonce, grantType, previousRefreshToken string) {
+	var user models.User
+
+	// Users removed from the organization since they authorized the client can't keep acting within it.
+	if err := userCollection.FindOne(ctx, bson.M{"userid": userID}).Decode(&user); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "the user no longer exists.")
+		return
+	}
+	if _, ok := helpers.FindMembership(user, tenant); tenant != "" && !ok {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "the user is no longer a member of the organization.")
+		return
+	}
+
+	// Generates the tokens with the existing token helpers, carrying the permissions of the user's roles and groups.
+	_, permissions, err := helpers.EffectiveAccess(ctx, user)
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "error occured while generating tokens.")
+		return
+	}
+	token, refreshToken, err := helpers.GenerateAllTokens(*user.Email, *user.FirstName, *user.LastName, *user.UserType, user.UserID, scope, tenant, permissions)
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "error occured while generating tokens.")
+		return
+	}
+	if err := helpers.StoreOAuthGrant(ctx, user.UserID, client.ClientID, scope, tenant, refreshToken, previousRefreshToken); err != nil {
+		if previousRefreshToken != "" {
+			oauthError(c, http.StatusBadRequest, "invalid_grant", "the refresh token is invalid or expired.")
+			return
+		}
+		oauthError(c, http.StatusInternalServerError, "server_error", "error occured while storing the grant.")
+		return
+	}
+
+	helpers.RecordAuditEvent(c, models.AuditEvent{
+		Type:     models.AuditOAuthToken,
+		ActorID:  user.UserID,
+		TargetID: client.ClientID,
+		Details:  map[string]string{"grant_type": grantType, "scope": scope},
+	})
+
+	response := gin.H{
+		"access_token":  token,
+		"token_type":    "Bearer",
+		"expires_in":    int((2 * time.Hour).Seconds()),
+		"refresh_token": refreshToken,
+		"scope":         scope,
+	}
+
+	// OpenID Connect clients are also told who the user is.
+	if scopes := strings.Fields(scope); helpers.RequestsIDToken(scopes) {
+		idToken, err := helpers.GenerateIDToken(user, client.ClientID, nonce, scopes, token)
+		if err != nil {
+			oauthError(c, http.StatusInternalServerError, "server_error", "error occured while generating the ID token.")
+			return
+		}
+		response["id_token"] = idToken
+	}
+
+	// Returns a code 200 status and the tokens.
+	c.JSON(http.StatusOK, response)
+}
+
+// Issues an access token to a confidential client acting on its own behalf, limited to the scopes it is allowed.
+// No refresh token is issued, as the client can authenticate again at any time.
+func exchangeClientCredentials(ctx context.Context, c *gin.Context) {
+	client, ok := authenticateOAuthClient(ctx, c)
+	if !ok {
+		return
+	}
+	if helpers.OAuthClientAuthMethod(client) == models.OAuthAuthNone || !helpers.OAuthClientAllowsGrant(client, models.OAuthGrantClientCredentials) {
+		oauthError(c, http.StatusBadRequest, "unauthorized_client", "the client can't use client credentials.")
+		return
+	}
+
+	scope, err := helpers.OAuthClientScope(client, c.PostForm("scope"))
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_scope", "the client isn't allowed the requested scope.")
+		return
+	}
+
+	token, err := helpers.GenerateClientToken(client.ClientID, scope, helpers.OAuthClientTokenMinutes)
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "error occured while generating the token.")
+		return
+	}
+
+	helpers.RecordAuditEvent(c, models.AuditEvent{
+		Type:     models.AuditOAuthToken,
+		ActorID:  client.ClientID,
+		TargetID: client.ClientID,
+		Details:  map[string]string{"grant_type": models.OAuthGrantClientCredentials, "scope": scope},
+	})
+
+	// Returns a code 200 status and the token.
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   helpers.OAuthClientTokenMinutes * 60,
+		"scope":        scope,
+	})
+}
+
+// Exchanges the current refresh token of a client's grant for new tokens, narrowed to the `scope` form field if it
+// is given. Refresh tokens are single-use: only the last one issued to the client for the user is accepted.
+func exchangeRefreshToken(ctx context.Context, c *gin.Context) {
+	client, ok := authenticateOAuthClient(ctx, c)
+	if !ok {
+		return
+	}
+
+	refreshToken := c.PostForm("refresh_token")
+	claims, msg := helpers.ValidateRefreshToken(refreshToken)
+	if msg != "" {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "the refresh token is invalid or expired.")
+		return
+	}
+	grant, err := helpers.FindOAuthGrant(ctx, client.ClientID, refreshToken)
+	if err != nil || grant.UserID != claims.UID {
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "the refresh token is invalid or expired.")
+		return
+	}
+
+	scope, err := helpers.NarrowScope(grant.Scope, c.PostForm("scope"))
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_scope", "the scope can't be wider than the one granted.")
+		return
+	}
+
+	issueUserTokens(ctx, c, client, grant.UserID, scope, grant.Tenant, "", models.OAuthGrantRefreshToken, refreshToken)
+}
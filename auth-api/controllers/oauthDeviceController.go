@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Body of the `POST /oauth/device` request.
+type deviceDecisionRequest struct {
+	UserCode string `json:"user_code" validate:"required"`
+	// Whether the user allowed the device to act on its behalf.
+	Approve bool `json:"approve"`
+}
+
+// Handler function for the `/oauth/device_authorization` route, which starts the authorization of a device that
+// can't open a browser. The device shows the user code for the user to enter elsewhere, and polls for its tokens.
+func DeviceAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// The device code must never be cached along the way.
+		c.Header("Cache-Control", "no-store")
+
+		client, ok := authenticateOAuthClient(ctx, c)
+		if !ok {
+			return
+		}
+		if !helpers.OAuthClientAllowsGrant(client, models.OAuthGrantDeviceCode) {
+			oauthError(c, http.StatusBadRequest, "unauthorized_client", "the client can't use device codes.")
+			return
+		}
+
+		scope, err := helpers.OAuthClientScope(client, c.PostForm("scope"))
+		if err != nil {
+			oauthError(c, http.StatusBadRequest, "invalid_scope", "the client isn't allowed the requested scope.")
+			return
+		}
+
+		deviceCode, userCode, err := helpers.CreateDeviceCode(ctx, client.ClientID, scope)
+		if err != nil {
+			oauthError(c, http.StatusInternalServerError, "server_error", "error occured while creating the device code.")
+			return
+		}
+
+		// Returns a code 200 status and the codes.
+		userCode = helpers.FormatUserCode(userCode)
+		c.JSON(http.StatusOK, gin.H{
+			"device_code":               deviceCode,
+			"user_code":                 userCode,
+			"verification_uri":          helpers.OAUTH_DEVICE_URL,
+			"verification_uri_complete": helpers.OAuthRedirect(helpers.OAUTH_DEVICE_URL, url.Values{"user_code": {userCode}}),
+			"expires_in":                helpers.DeviceCodeMinutes * 60,
+			"interval":                  helpers.DeviceCodeInterval,
+		})
+	}
+}
+
+// Handler function for the `GET /oauth/device` route, which tells the user entering a user code which client asks
+// for which scopes.
+func GetDeviceAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		authorization, err := helpers.FindPendingDeviceCode(ctx, c.Query("user_code"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the code is invalid or expired."})
+			return
+		}
+		client, err := helpers.FindOAuthClient(ctx, authorization.ClientID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the client doesn't exist."})
+			return
+		}
+
+		// Returns a code 200 status and what the user is asked to approve.
+		c.JSON(http.StatusOK, gin.H{
+			"user_code": helpers.FormatUserCode(authorization.UserCode),
+			"client":    gin.H{"client_id": client.ClientID, "name": client.Name},
+			"scopes":    strings.Fields(authorization.Scope),
+		})
+	}
+}
+
+// Handler function for the `POST /oauth/device` route, which records the user's answer for the device showing the
+// user code. The device receives its tokens the next time it polls.
+func DecideDeviceAuthorization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request deviceDecisionRequest
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		authorization, err := helpers.FindPendingDeviceCode(ctx, request.UserCode)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the code is invalid or expired."})
+			return
+		}
+
+		// The device can't be given more than the user's own token allows.
+		scope, err := helpers.NarrowScope(c.GetString("scope"), authorization.Scope)
+		if err != nil && request.Approve {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you can't grant the requested scope."})
+			return
+		}
+
+		err = helpers.DecideDeviceCode(ctx, request.UserCode, c.GetString("user_id"), scope, c.GetString("tenant"), request.Approve)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the code is invalid or expired."})
+			return
+		}
+
+		outcome, status := models.AuditOutcomeSuccess, models.DeviceCodeApproved
+		if !request.Approve {
+			outcome, status = models.AuditOutcomeFailure, models.DeviceCodeDenied
+		}
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditOAuthDevice,
+			Outcome:  outcome,
+			TargetID: authorization.ClientID,
+			Details:  map[string]string{"scope": authorization.Scope, "status": status},
+		})
+
+		// Returns a code 200 status and the decision.
+		c.JSON(http.StatusOK, gin.H{"status": status})
+	}
+}
+
+// Exchanges a device code for tokens once the user approved it, telling the device to keep polling, or to poll more
+// slowly, until then.
+func exchangeDeviceCode(ctx context.Context, c *gin.Context) {
+	client, ok := authenticateOAuthClient(ctx, c)
+	if !ok {
+		return
+	}
+	if !helpers.OAuthClientAllowsGrant(client, models.OAuthGrantDeviceCode) {
+		oauthError(c, http.StatusBadRequest, "unauthorized_client", "the client can't use device codes.")
+		return
+	}
+
+	authorization, slowDown, err := helpers.PollDeviceCode(ctx, c.PostForm("device_code"), client.ClientID)
+	switch {
+	case err != nil:
+		oauthError(c, http.StatusBadRequest, "invalid_grant", "the device code is invalid.")
+	case time.Now().After(authorization.ExpiresAt):
+		oauthError(c, http.StatusBadRequest, "expired_token", "the device code expired.")
+	case slowDown:
+		oauthError(c, http.StatusBadRequest, "slow_down", "the device is polling too fast.")
+	case authorization.Status == models.DeviceCodePending:
+		oauthError(c, http.StatusBadRequest, "authorization_pending", "the user hasn't approved the device yet.")
+	case authorization.Status == models.DeviceCodeDenied:
+		oauthError(c, http.StatusBadRequest, "access_denied", "the user denied the request.")
+	default:
+		issueUserTokens(ctx, c, client, authorization.UserID, authorization.Scope, authorization.Tenant, "", models.OAuthGrantDeviceCode, "")
+	}
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Handler function for the `/.well-known/openid-configuration` route, which describes the OpenID Connect provider
+// to its clients.
+func OpenIDConfiguration() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Returns a code 200 status and the provider's metadata.
+		c.JSON(http.StatusOK, gin.H{
+			"issuer":                                           helpers.OAUTH_ISSUER,
+			"authorization_endpoint":                           helpers.OAUTH_AUTHORIZATION_URL,
+			"token_endpoint":                                   helpers.OAUTH_ISSUER + "/oauth/token",
+			"userinfo_endpoint":                                helpers.OAUTH_ISSUER + "/userinfo",
+			"device_authorization_endpoint":                    helpers.OAUTH_ISSUER + "/oauth/device_authorization",
+			"jwks_uri":                                         helpers.OAUTH_ISSUER + "/.well-known/jwks.json",
+			"scopes_supported":                                 []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail, models.ScopePhone},
+			"response_types_supported":                         []string{"code"},
+			"response_modes_supported":                         []string{"query"},
+			"grant_types_supported":                            []string{models.OAuthGrantAuthorizationCode, models.OAuthGrantClientCredentials, models.OAuthGrantDeviceCode, models.OAuthGrantRefreshToken},
+			"subject_types_supported":                          []string{"public"},
+			"id_token_signing_alg_values_supported":            []string{"RS256"},
+			"code_challenge_methods_supported":                 []string{"S256"},
+			"token_endpoint_auth_methods_supported":            []string{models.OAuthAuthClientSecret, "client_secret_post", models.OAuthAuthPrivateKeyJWT, models.OAuthAuthNone},
+			"token_endpoint_auth_signing_alg_values_supported": []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+			"claims_supported": []string{
+				"sub", "iss", "aud", "exp", "iat", "nonce", "at_hash", "name", "given_name", "family_name",
+				"updated_at", "email", "email_verified", "phone_number", "phone_number_verified",
+			},
+		})
+	}
+}
+
+// Handler function for the `/.well-known/jwks.json` route, which publishes the key verifying the ID tokens.
+func OpenIDKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Returns a code 200 status and the key set.
+		c.JSON(http.StatusOK, helpers.OpenIDKeySet())
+	}
+}
+
+// Handler function for the `/userinfo` route, which returns the claims about the user of an access token scoped for
+// `openid`. The token is read from the `Authorization` header, as OpenID Connect clients send it, or the `token` one.
+func UserInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var user models.User
+		defer cancel()
+
+		token := c.Request.Header.Get("token")
+		if bearer := c.Request.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+			token = strings.TrimPrefix(bearer, "Bearer ")
+		}
+
+		// Only access tokens of users, scoped for `openid`, are accepted.
+		claims, msg := helpers.ValidateToken(token)
+		var scopes []string
+		if msg == "" {
+			scopes, _ = helpers.ParseScope(claims.Scope)
+		}
+		if msg != "" || claims.UID == "" || !helpers.ScopeCovers(scopes, []string{models.ScopeOpenID}) {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": "the access token is invalid, expired or not scoped for openid."})
+			return
+		}
+
+		if err := userCollection.FindOne(ctx, bson.M{"userid": claims.UID}).Decode(&user); err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": "the user no longer exists."})
+			return
+		}
+
+		// Returns a code 200 status and the claims.
+		c.JSON(http.StatusOK, helpers.OpenIDClaims(user, scopes))
+	}
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Tests that the discovery metadata points clients at the routes and algorithms the provider actually serves.
+func TestOpenIDConfiguration(t *testing.T) {
+	router := gin.New()
+	router.GET(".well-known/openid-configuration", OpenIDConfiguration())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &metadata); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d and %v", recorder.Code, err)
+	}
+
+	want := map[string]string{
+		"issuer":                        helpers.OAUTH_ISSUER,
+		"token_endpoint":                helpers.OAUTH_ISSUER + "/oauth/token",
+		"userinfo_endpoint":             helpers.OAUTH_ISSUER + "/userinfo",
+		"device_authorization_endpoint": helpers.OAUTH_ISSUER + "/oauth/device_authorization",
+		"jwks_uri":                      helpers.OAUTH_ISSUER + "/.well-known/jwks.json",
+	}
+	for name, value := range want {
+		if metadata[name] != value {
+			t.Errorf("got %s %v, want %q", name, metadata[name], value)
+		}
+	}
+
+	contains := map[string]string{
+		"scopes_supported":                      models.ScopeOpenID,
+		"response_types_supported":              "code",
+		"grant_types_supported":                 models.OAuthGrantRefreshToken,
+		"id_token_signing_alg_values_supported": "RS256",
+		"code_challenge_methods_supported":      "S256",
+		"claims_supported":                      "at_hash",
+	}
+	for name, value := range contains {
+		values, _ := metadata[name].([]interface{})
+		found := false
+		for _, candidate := range values {
+			found = found || candidate == value
+		}
+		if !found {
+			t.Errorf("got %s %v, want it to include %q", name, metadata[name], value)
+		}
+	}
+}
+
+// Tests that the key set route publishes the key of the ID tokens.
+func TestOpenIDKeys(t *testing.T) {
+	router := gin.New()
+	router.GET(".well-known/jwks.json", OpenIDKeys())
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	var keySet struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &keySet); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d and %v", recorder.Code, err)
+	}
+	if len(keySet.Keys) != 1 || keySet.Keys[0]["kid"] != helpers.OpenIDKeySet()["keys"].([]map[string]string)[0]["kid"] {
+		t.Fatalf("got %v", keySet.Keys)
+	}
+}
+
+// Tests that the user info route rejects tokens that aren't access tokens of users scoped for `openid`.
+func TestUserInfoRejectsTokens(t *testing.T) {
+	userToken := func(scope string) string {
+		token, _, err := helpers.GenerateAllTokens("ada@example.com", "Ada", "Lovelace", models.RoleUser, "user-1", scope, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	clientToken, err := helpers.GenerateClientToken("client-1", models.ScopeOpenID, helpers.OAuthClientTokenMinutes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"no token", ""},
+		{"malformed token", "not-a-token"},
+		{"permission scope", userToken(models.PermissionUsersRead)},
+		{"profile without openid", userToken(models.ScopeProfile + " " + models.ScopeEmail)},
+		{"client token", clientToken},
+	}
+
+	router := gin.New()
+	router.GET("userinfo", UserInfo())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+			if test.token != "" {
+				request.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusUnauthorized || recorder.Header().Get("WWW-Authenticate") != `Bearer error="invalid_token"` {
+				t.Fatalf("got status %d with %q: %s", recorder.Code, recorder.Header().Get("WWW-Authenticate"), recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creates `organizationCollection` variable that uses the `organization` collection from MongoDB instance.
+var organizationCollection *mongo.Collection = database.OpenCollection(database.Client, "organization")
+
+// Body of the `/orgs/:org_id/members/:user_id` request.
+type membershipRequest struct {
+	// Roles the user holds within the organization, replacing the ones it held before.
+	Roles []string `json:"roles" validate:"dive,required"`
+}
+
+// Handler function for the `POST /orgs` route.
+func CreateOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var organization models.Organization
+		defer cancel()
+
+		// Parses and validates the `organization` variable from the HTTP request.
+		if err := c.BindJSON(&organization); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(organization); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+		if organization.EmailUniqueness == "" {
+			organization.EmailUniqueness = models.EmailUniquenessGlobal
+		}
+
+		organization.ID = primitive.NewObjectID()
+		organization.OrgID = organization.ID.Hex()
+		organization.CreatedAt = time.Now().UTC()
+		organization.UpdatedAt = organization.CreatedAt
+
+		_, err := organizationCollection.InsertOne(ctx, organization)
+		// Error handling for the above `InsertOne()` function.
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "an organization with this slug already exists."})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating the organization."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditOrgCreate, TargetID: organization.OrgID, Details: map[string]string{"slug": organization.Slug}})
+
+		// Returns a code 201 status and the created organization.
+		c.JSON(http.StatusCreated, organization)
+	}
+}
+
+// Handler function for the `GET /orgs` route.
+func GetOrganizations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := organizationCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "slug", Value: 1}}))
+		// Error handling for the above `Find()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing organizations."})
+			return
+		}
+
+		organizations := []models.Organization{}
+		if err = cursor.All(ctx, &organizations); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing organizations."})
+			return
+		}
+
+		// Returns a code 200 status and the organizations.
+		c.JSON(http.StatusOK, organizations)
+	}
+}
+
+// Handler function for the `GET /orgs/:org_id` route, which the admins of the organization may also call.
+func GetOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgId := c.Param("org_id")
+		if err := helpers.Authorize(c, models.PermissionOrgsRead, models.PolicyResource{Type: "organization", ID: orgId, Tenant: orgId}); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		organization, err := helpers.FindOrganization(ctx, orgId)
+		// Error handling for the above `FindOrganization()` function.
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the organization doesn't exist."})
+			return
+		}
+
+		// Returns a code 200 status and the organization.
+		c.JSON(http.StatusOK, organization)
+	}
+}
+
+// Handler function for the `PUT /orgs/:org_id/members/:user_id` route, which adds a user to an organization or
+// replaces the roles it holds there. The admins of the organization can change the roles of its members, but they
+// bring in new users through invitations, which the users have to accept. Adding them directly is left to the admins
+// of the service.
+func SetMembership() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request membershipRequest
+		var user models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+		if request.Roles == nil {
+			request.Roles = []string{}
+		}
+
+		orgId, userId := c.Param("org_id"), c.Param("user_id")
+		if _, err := helpers.FindOrganization(ctx, orgId); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the organization doesn't exist."})
+			return
+		}
+		if err := userCollection.FindOne(ctx, bson.M{"userid": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the user doesn't exist."})
+			return
+		}
+
+		// Only the admins of the service can bring in users from outside of the organization.
+		resource := models.PolicyResource{Type: "organization", ID: orgId}
+		_, member := helpers.FindMembership(user, orgId)
+		if member {
+			resource.Tenant = orgId
+		}
+		if err := helpers.Authorize(c, models.PermissionOrgsMembers, resource); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Only existing roles whose permissions are all held by the caller within the organization can be given.
+		if !authorizeGrantedRoles(ctx, c, request.Roles, orgId) {
+			return
+		}
+
+		// Replaces the roles of an existing membership, or adds one, without ever duplicating the membership.
+		var result *mongo.UpdateResult
+		var err error
+		if member {
+			result, err = userCollection.UpdateOne(ctx, bson.M{"userid": userId, "memberships.orgid": orgId}, bson.D{
+				{Key: "$set", Value: bson.D{{Key: "memberships.$.roles", Value: request.Roles}, {Key: "updatedat", Value: time.Now()}}},
+			})
+		} else {
+			membership := models.OrgMembership{OrgID: orgId, Roles: request.Roles, JoinedAt: time.Now()}
+			result, err = userCollection.UpdateOne(ctx, bson.M{"userid": userId, "memberships.orgid": bson.M{"$ne": orgId}}, bson.D{
+				{Key: "$push", Value: bson.D{{Key: "memberships", Value: membership}}},
+				{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+			})
+		}
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while updating the membership."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "the membership changed in the meantime, try again."})
+			return
+		}
+
+		action := "add"
+		if member {
+			action = "update"
+		}
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditOrgMembership,
+			TargetID: userId,
+			Details:  map[string]string{"action": action, "org_id": orgId, "roles": strings.Join(request.Roles, " ")},
+		})
+
+		// Returns a code 200 status and the membership.
+		c.JSON(http.StatusOK, gin.H{"org_id": orgId, "user_id": userId, "roles": request.Roles})
+	}
+}
+
+// Handler function for the `DELETE /orgs/:org_id/members/:user_id` route. Tokens the user holds for the organization
+// stop granting its roles straight away, and can't be refreshed anymore.
+func RemoveMembership() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgId, userId := c.Param("org_id"), c.Param("user_id")
+		if err := helpers.Authorize(c, models.PermissionOrgsMembers, models.PolicyResource{Type: "organization", ID: orgId, Tenant: orgId}); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := userCollection.UpdateOne(ctx, bson.M{"userid": userId, "memberships.orgid": orgId}, bson.D{
+			{Key: "$pull", Value: bson.D{{Key: "memberships", Value: bson.D{{Key: "orgid", Value: orgId}}}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while removing the membership."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the user isn't a member of this organization."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditOrgMembership,
+			TargetID: userId,
+			Details:  map[string]string{"action": "remove", "org_id": orgId},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"removed": userId})
+	}
+}
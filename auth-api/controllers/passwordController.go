@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Body of the `/users/password` request.
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// Body of the `/users/password/reset/request` request.
+type passwordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// Body of the `/users/login/password-change` request.
+type expiredPasswordRequest struct {
+	PasswordChangeToken string `json:"password_change_token" validate:"required"`
+	NewPassword         string `json:"new_password" validate:"required"`
+}
+
+// Body of the `/users/password/reset` request.
+type resetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// Handler function for the `/users/password` route, which changes the password of the authenticated user.
+func ChangePassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request changePasswordRequest
+		var user models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Finds the authenticated user using the `user_id` set by the `Authenticate()` middleware.
+		if err := userCollection.FindOne(ctx, bson.M{"userid": c.GetString("user_id")}).Decode(&user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Requires the current password, so a stolen access token alone can't take over the account.
+		if passwordIsValid, msg := VerifyPassword(request.CurrentPassword, *user.Password); !passwordIsValid {
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:     models.AuditPasswordChange,
+				Outcome:  models.AuditOutcomeFailure,
+				TargetID: user.UserID,
+				Details:  map[string]string{"reason": "wrong_password"},
+			})
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := setPassword(ctx, user, request.NewPassword); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditPasswordChange, TargetID: user.UserID})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"message": "the password was changed."})
+	}
+}
+
+// Handler function for the `/users/password/reset/request` route, which emails a password reset link.
+func RequestPasswordReset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request passwordResetRequest
+		var foundUser models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// The same response is returned whether or not the email belongs to a user.
+		response := gin.H{"message": "if the email is registered, a password reset link has been sent."}
+
+		// Finds the user the reset is requested for, in the organization of the `tenant_id` query parameter if it is present.
+		filter, err := helpers.AccountFilter(ctx, request.Email, c.Query("tenant_id"))
+		if err == nil {
+			err = userCollection.FindOne(ctx, filter).Decode(&foundUser)
+		}
+		if err != nil {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		// Generates the link token, which stops working as soon as the password changes.
+		token, err := helpers.GeneratePasswordResetToken(foundUser.UserID, *foundUser.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the link."})
+			return
+		}
+
+		// Emails the link to the user, in the background so that the response takes as long whether or not the email
+		// belongs to a user.
+		link := fmt.Sprintf("%s?token=%s", helpers.PASSWORD_RESET_URL, token)
+		body := fmt.Sprintf("Use the link below to choose a new password. It expires in %d minutes.\n\n%s\n\nIf you didn't request this, you can ignore this email.", helpers.PasswordResetMinutes, link)
+		go sendSecurityEmail(*foundUser.Email, "Reset your password", body)
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// Handler function for the `/users/password/reset` route, which sets a new password using a reset link token.
+func ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request resetPasswordRequest
+		var foundUser models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Validates the signature, audience and expiry of the link token.
+		claims, msg := helpers.ValidatePasswordResetToken(request.Token)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the reset link is invalid or expired."})
+			return
+		}
+
+		// Finds the user and makes sure the password hasn't changed since the link was issued, which makes the link single-use.
+		err := userCollection.FindOne(ctx, bson.M{"userid": claims.Subject}).Decode(&foundUser)
+		if err != nil || helpers.HashToken(*foundUser.Password) != claims.PasswordHash {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the reset link is invalid or expired."})
+			return
+		}
+
+		if err := setPassword(ctx, foundUser, request.NewPassword); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditPasswordReset, ActorID: foundUser.UserID, TargetID: foundUser.UserID})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"message": "the password was reset."})
+	}
+}
+
+// Handler function for the `/users/login/password-change` route, which completes a login whose password has expired.
+func ChangeExpiredPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request expiredPasswordRequest
+		var foundUser models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Validates the challenge token handed out by `Login()`.
+		claims, msg := helpers.ValidatePasswordChangeToken(request.PasswordChangeToken)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		// Finds the user the challenge was issued for, and makes sure the expired password hasn't been changed since,
+		// which makes the challenge single-use. `setPassword()` only replaces that same password.
+		err := userCollection.FindOne(ctx, bson.M{"userid": claims.Subject}).Decode(&foundUser)
+		if err != nil || helpers.HashToken(*foundUser.Password) != claims.PasswordHash {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the token is invalid"})
+			return
+		}
+
+		if err := setPassword(ctx, foundUser, request.NewPassword); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditPasswordChange,
+			ActorID:  foundUser.UserID,
+			TargetID: foundUser.UserID,
+			Details:  map[string]string{"reason": "expired"},
+		})
+
+		// Carries on with the rest of the login, now that the password is up to date.
+		respondWithLogin(c, foundUser)
+	}
+}
+
+// Checks `password` against the password policy and stores its hash as the new password of `user`.
+func setPassword(ctx context.Context, user models.User, password string) error {
+	if err := helpers.CurrentPasswordPolicy.Validate(password, passwordPersonalInfo(user)...); err != nil {
+		return err
+	}
+
+	// Refuses the current password and the most recent ones kept in the history.
+	if helpers.PasswordReused(password, *user.Password, user.PasswordHistory) {
+		return fmt.Errorf("the password must differ from your last %d passwords.", helpers.PASSWORD_HISTORY_SIZE+1)
+	}
+
+	hash, err := helpers.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// Only replaces the password that was checked, so concurrent changes or resets can't both succeed.
+	// The replaced hash is pushed to the front of the history, which is capped at `PASSWORD_HISTORY_SIZE`.
+	now := time.Now()
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"userid": user.UserID, "password": *user.Password},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "password", Value: hash},
+				{Key: "passwordchangedat", Value: now},
+				{Key: "updatedat", Value: now},
+			}},
+			{Key: "$push", Value: bson.D{{Key: "passwordhistory", Value: bson.D{
+				{Key: "$each", Value: []string{*user.Password}},
+				{Key: "$position", Value: 0},
+				{Key: "$slice", Value: helpers.PASSWORD_HISTORY_SIZE},
+			}}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return errors.New("the password was changed in the meantime.")
+	}
+
+	return nil
+}
+
+// Returns the values of `user` a password must not contain: the names and the local part of the email.
+func passwordPersonalInfo(user models.User) []string {
+	info := []string{}
+	if user.FirstName != nil {
+		info = append(info, *user.FirstName)
+	}
+	if user.LastName != nil {
+		info = append(info, *user.LastName)
+	}
+	if user.Email != nil {
+		info = append(info, strings.Split(*user.Email, "@")[0])
+	}
+
+	return info
+}
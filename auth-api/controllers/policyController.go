@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Body of the `/policy/explain` request.
+type policyExplainRequest struct {
+	// User to evaluate the request for, defaulting to the caller.
+	UserID string `json:"user_id"`
+	// Scope of the hypothetical token, defaulting to an unrestricted one.
+	Scope string `json:"scope"`
+	// Organization the hypothetical token acts within, defaulting to none.
+	Tenant   string                `json:"tenant"`
+	Action   string                `json:"action" validate:"required"`
+	Resource models.PolicyResource `json:"resource"`
+	// Candidate policy to evaluate instead of the current one, to try out rules before deploying them.
+	Policy *models.Policy `json:"policy"`
+}
+
+// Handler function for the `/policy/explain` route, a dry run that evaluates a request against the policy without
+// performing it, and explains how every rule was evaluated.
+func ExplainPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request policyExplainRequest
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+		if request.UserID == "" {
+			request.UserID = c.GetString("user_id")
+		}
+
+		// Uses the candidate policy, once validated, or the current one.
+		policy := helpers.CurrentPolicy
+		if request.Policy != nil {
+			if err := helpers.ValidatePolicy(*request.Policy); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			policy = *request.Policy
+		}
+
+		// Resolves the roles and permissions of the user the request is evaluated for.
+		subject, err := helpers.LoadPolicySubject(ctx, request.UserID, request.Scope, request.Tenant)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the user doesn't exist or the scope is invalid."})
+			return
+		}
+
+		decision := helpers.EvaluatePolicy(policy, helpers.PolicyAttributes(subject, request.Action, request.Resource, c), true)
+
+		// Records explanations made for other users, as they reveal their permissions.
+		if request.UserID != c.GetString("user_id") {
+			helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditAdminReadUser, TargetID: request.UserID, Details: map[string]string{"policy_explain": request.Action}})
+		}
+
+		// Returns a code 200 status, the subject and the decision.
+		c.JSON(http.StatusOK, gin.H{"subject": subject, "decision": decision})
+	}
+}
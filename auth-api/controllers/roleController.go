@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creates `roleCollection` variable that uses the `role` collection from MongoDB instance.
+var roleCollection *mongo.Collection = database.OpenCollection(database.Client, "role")
+
+// Body of the `/users/:user_id/roles` request.
+type roleAssignmentRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// Checks the roles named `names` exist and that the caller holds every permission they grant within the organization
+// `tenant`, so they can't be used to escalate privileges. Otherwise it responds with an error and returns false.
+func authorizeGrantedRoles(ctx context.Context, c *gin.Context, names []string, tenant string) bool {
+	var roles []models.Role
+	cursor, err := roleCollection.Find(ctx, bson.M{"name": bson.M{"$in": names}})
+	if err == nil {
+		err = cursor.All(ctx, &roles)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while finding the roles."})
+		return false
+	}
+
+	for _, name := range names {
+		found := false
+		for _, role := range roles {
+			found = found || role.Name == name
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the role " + name + " doesn't exist."})
+			return false
+		}
+	}
+	for _, role := range roles {
+		for _, permission := range role.Permissions {
+			if err := helpers.Authorize(c, permission, models.PolicyResource{Type: "role", ID: role.Name, Tenant: tenant}); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can't give a role granting " + permission + "."})
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Handler function for the `POST /roles` route.
+func CreateRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var role models.Role
+		defer cancel()
+
+		// Parses and validates the `role` variable from the HTTP request.
+		if err := c.BindJSON(&role); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(role); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Checks every permission is well formed, and held by the caller so roles can't be used to escalate privileges.
+		for _, permission := range role.Permissions {
+			if !helpers.ValidPermission(permission) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the permission " + permission + " is invalid."})
+				return
+			}
+			if err := helpers.Authorize(c, permission, models.PolicyResource{Type: "role", ID: role.Name}); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can't grant the permission " + permission + "."})
+				return
+			}
+		}
+
+		role.ID = primitive.NewObjectID()
+		role.Builtin = false
+		role.CreatedAt = time.Now().UTC()
+		role.UpdatedAt = role.CreatedAt
+
+		_, err := roleCollection.InsertOne(ctx, role)
+		// Error handling for the above `InsertOne()` function.
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a role with this name already exists."})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating the role."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditRoleCreate, Details: map[string]string{"role": role.Name}})
+
+		// Returns a code 201 status and the created role.
+		c.JSON(http.StatusCreated, role)
+	}
+}
+
+// Handler function for the `GET /roles` route.
+func GetRoles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := roleCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		// Error handling for the above `Find()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing roles."})
+			return
+		}
+
+		roles := []models.Role{}
+		if err = cursor.All(ctx, &roles); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing roles."})
+			return
+		}
+
+		// Returns a code 200 status and the roles.
+		c.JSON(http.StatusOK, roles)
+	}
+}
+
+// Handler function for the `POST /users/:user_id/roles` route.
+func AssignRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request roleAssignmentRequest
+		var role models.Role
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Finds the role, which must exist.
+		if err := roleCollection.FindOne(ctx, bson.M{"name": request.Role}).Decode(&role); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the role doesn't exist."})
+			return
+		}
+
+		// Only roles whose permissions are all held by the caller can be assigned.
+		for _, permission := range role.Permissions {
+			if err := helpers.Authorize(c, permission, models.PolicyResource{Type: "role", ID: role.Name}); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can't assign a role granting " + permission + "."})
+				return
+			}
+		}
+
+		result, err := userCollection.UpdateOne(ctx, bson.M{"userid": c.Param("user_id")}, bson.D{
+			{Key: "$addToSet", Value: bson.D{{Key: "roles", Value: role.Name}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while assigning the role."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the user doesn't exist."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditRoleChange,
+			TargetID: c.Param("user_id"),
+			Details:  map[string]string{"action": "assign", "role": role.Name},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"assigned": role.Name})
+	}
+}
+
+// Handler function for the `DELETE /users/:user_id/roles/:role` route. The role named after the user's `user_type`
+// isn't affected.
+func RemoveRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := userCollection.UpdateOne(ctx, bson.M{"userid": c.Param("user_id"), "roles": c.Param("role")}, bson.D{
+			{Key: "$pull", Value: bson.D{{Key: "roles", Value: c.Param("role")}}},
+			{Key: "$set", Value: bson.D{{Key: "updatedat", Value: time.Now()}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while removing the role."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the user doesn't have this role."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditRoleChange,
+			TargetID: c.Param("user_id"),
+			Details:  map[string]string{"action": "remove", "role": c.Param("role")},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"removed": c.Param("role")})
+	}
+}
+
+// Handler function for the `GET /users/:user_id/permissions` route, which lists the roles a user holds, how it came
+// to hold each of them and the permissions they grant. With the `permission` query parameter, it also explains which
+// roles grant that permission. These are the user's current permissions, which its tokens carry once refreshed.
+func GetUserPermissions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var user models.User
+		defer cancel()
+
+		userId := c.Param("user_id")
+		if err := userCollection.FindOne(ctx, bson.M{"userid": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the user doesn't exist."})
+			return
+		}
+
+		grants, err := helpers.UserRoleGrants(ctx, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while resolving the user's roles."})
+			return
+		}
+		_, permissions, err := helpers.EffectiveAccess(ctx, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while resolving the user's roles."})
+			return
+		}
+		response := gin.H{"user_id": userId, "roles": grants, "permissions": permissions}
+
+		if permission := c.Query("permission"); permission != "" {
+			if !helpers.ValidPermission(permission) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the permission " + permission + " is invalid."})
+				return
+			}
+			grantedBy, err := helpers.ExplainPermission(ctx, user, permission)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while resolving the user's roles."})
+				return
+			}
+			response["permission"] = permission
+			response["granted"] = len(grantedBy) > 0
+			response["granted_by"] = grantedBy
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditAdminReadUser, TargetID: userId, Details: map[string]string{"permissions": c.Query("permission")}})
+
+		// Returns a code 200 status and the explanation.
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Creates `apiKeyCollection` variable that uses the `api_key` collection from MongoDB instance.
+var apiKeyCollection *mongo.Collection = database.OpenCollection(database.Client, "api_key")
+
+// Body of the `/service-accounts` request.
+type serviceAccountRequest struct {
+	Name  string   `json:"name" validate:"required,min=2,max=100"`
+	Roles []string `json:"roles" validate:"dive,required"`
+}
+
+// Body of the `/service-accounts/:user_id/keys` request.
+type apiKeyRequest struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+	// Space separated scopes the key is limited to, which must be listed explicitly.
+	Scope string `json:"scope" validate:"required"`
+	// Number of days the key stays valid for, defaulting to `API_KEY_EXPIRY_DAYS`.
+	ExpiresInDays int `json:"expires_in_days" validate:"min=0,max=3650"`
+}
+
+// Handler function for the `POST /service-accounts` route, which creates a user without a password that can only
+// authenticate with API keys.
+func CreateServiceAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request serviceAccountRequest
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+		if request.Roles == nil {
+			request.Roles = []string{}
+		}
+
+		// Only roles whose permissions are all held by the caller can be given to the service account.
+		if !authorizeGrantedRoles(ctx, c, request.Roles, "") {
+			return
+		}
+
+		userType := models.RoleUser
+		now := time.Now()
+		user := models.User{
+			ID:              primitive.NewObjectID(),
+			FirstName:       &request.Name,
+			UserType:        &userType,
+			Roles:           request.Roles,
+			Groups:          []string{},
+			Memberships:     []models.OrgMembership{},
+			ServiceAccount:  true,
+			PasswordHistory: []string{},
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		user.UserID = user.ID.Hex()
+
+		if _, err := userCollection.InsertOne(ctx, user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating the service account."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditServiceAccountCreate,
+			TargetID: user.UserID,
+			Details:  map[string]string{"name": request.Name, "roles": strings.Join(request.Roles, " ")},
+		})
+
+		// Returns a code 201 status and the service account.
+		c.JSON(http.StatusCreated, user)
+	}
+}
+
+// Handler function for the `GET /service-accounts` route.
+func GetServiceAccounts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := userCollection.Find(ctx, bson.M{"serviceaccount": true}, options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}}))
+		// Error handling for the above `Find()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing service accounts."})
+			return
+		}
+
+		users := []models.User{}
+		if err = cursor.All(ctx, &users); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing service accounts."})
+			return
+		}
+
+		// Returns a code 200 status and the service accounts.
+		c.JSON(http.StatusOK, users)
+	}
+}
+
+// Handler function for the `POST /service-accounts/:user_id/keys` route. The key is only ever returned in this response.
+func CreateAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request apiKeyRequest
+		var user models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Service accounts have no account of their own to manage, so keys can't be scoped for it.
+		scopes, err := helpers.ParseScope(request.Scope)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if helpers.ScopeCovers(scopes, []string{models.ScopeAccount}) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API keys can't be scoped for " + models.ScopeAccount + "."})
+			return
+		}
+
+		if err := userCollection.FindOne(ctx, bson.M{"userid": c.Param("user_id"), "serviceaccount": true}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the service account doesn't exist."})
+			return
+		}
+
+		// A key acts with every permission of the service account, which the caller must therefore hold.
+		_, permissions, err := helpers.EffectiveAccess(ctx, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while resolving the service account's roles."})
+			return
+		}
+		for _, permission := range permissions {
+			if err := helpers.Authorize(c, permission, models.PolicyResource{Type: "service_account", ID: user.UserID}); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "you can't create keys for a service account granted " + permission + "."})
+				return
+			}
+		}
+
+		key, keyId, prefix, err := helpers.GenerateAPIKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the key."})
+			return
+		}
+		days := request.ExpiresInDays
+		if days == 0 {
+			days = helpers.API_KEY_EXPIRY_DAYS
+		}
+		now := time.Now().UTC()
+		apiKey := models.APIKey{
+			ID:        primitive.NewObjectID(),
+			KeyID:     keyId,
+			UserID:    user.UserID,
+			Name:      request.Name,
+			Prefix:    prefix,
+			Hash:      helpers.HashToken(key),
+			Scope:     strings.Join(scopes, " "),
+			ExpiresAt: now.AddDate(0, 0, days),
+			CreatedBy: c.GetString("user_id"),
+			CreatedAt: now,
+		}
+
+		if _, err := apiKeyCollection.InsertOne(ctx, apiKey); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while creating the key."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditAPIKeyCreate,
+			TargetID: user.UserID,
+			Details:  map[string]string{"key_id": keyId, "scope": apiKey.Scope},
+		})
+
+		// Returns a code 201 status, the key and its details.
+		c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": apiKey})
+	}
+}
+
+// Handler function for the `GET /service-accounts/:user_id/keys` route.
+func GetAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := apiKeyCollection.Find(ctx, bson.M{"userid": c.Param("user_id")}, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}))
+		// Error handling for the above `Find()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing keys."})
+			return
+		}
+
+		keys := []models.APIKey{}
+		if err = cursor.All(ctx, &keys); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured whilst listing keys."})
+			return
+		}
+
+		// Returns a code 200 status and the keys.
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+// Handler function for the `DELETE /service-accounts/:user_id/keys/:key_id` route, which revokes a key straight away.
+func RevokeAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := apiKeyCollection.UpdateOne(ctx,
+			bson.M{"keyid": c.Param("key_id"), "userid": c.Param("user_id"), "revokedat": time.Time{}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "revokedat", Value: time.Now().UTC()}}}},
+		)
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while revoking the key."})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "the key doesn't exist or is already revoked."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditAPIKeyRevoke,
+			TargetID: c.Param("user_id"),
+			Details:  map[string]string{"key_id": c.Param("key_id")},
+		})
+
+		// Returns a code 200 status.
+		c.JSON(http.StatusOK, gin.H{"revoked": c.Param("key_id")})
+	}
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Body of the `/users/token/refresh` request.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+	// Space separated scopes to narrow the new tokens to, defaulting to the scope of the refresh token.
+	Scope string `json:"scope"`
+}
+
+// Handler function for the `/users/token/refresh` route, which exchanges a refresh token for new tokens.
+// Refresh tokens are single-use: only the last one issued to a user is accepted.
+func RefreshTokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request refreshRequest
+		var foundUser models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Validates the signature, audience and expiry of the refresh token.
+		claims, msg := helpers.ValidateRefreshToken(request.RefreshToken)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the refresh token is invalid or expired."})
+			return
+		}
+
+		// Finds the user the token was issued to, which must still hold it.
+		err := userCollection.FindOne(ctx, bson.M{"userid": claims.UID, "refreshtoken": request.RefreshToken}).Decode(&foundUser)
+		if err != nil {
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:     models.AuditTokenRefresh,
+				Outcome:  models.AuditOutcomeFailure,
+				ActorID:  claims.UID,
+				TargetID: claims.UID,
+				Details:  map[string]string{"reason": "refresh_token_not_current"},
+			})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the refresh token is invalid or expired."})
+			return
+		}
+
+		// Narrows the scope if asked to, it can never be widened.
+		scope, err := helpers.NarrowScope(claims.Scope, request.Scope)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Users removed from the organization of the token can't keep acting within it.
+		if _, ok := helpers.FindMembership(foundUser, claims.Tenant); claims.Tenant != "" && !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you aren't a member of this organization."})
+			return
+		}
+
+		// Generates new tokens, carrying the user's current details and the permissions of its current roles and groups.
+		_, permissions, err := helpers.EffectiveAccess(ctx, foundUser)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating tokens."})
+			return
+		}
+		token, refreshToken, err := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.FirstName, *foundUser.LastName, *foundUser.UserType, foundUser.UserID, scope, claims.Tenant, permissions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating tokens."})
+			return
+		}
+
+		// Stores the new tokens only if the used refresh token is still current, so it can't be redeemed twice.
+		result, err := userCollection.UpdateOne(ctx, bson.M{"userid": foundUser.UserID, "refreshtoken": request.RefreshToken}, bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "token", Value: token},
+				{Key: "refreshtoken", Value: refreshToken},
+				{Key: "updatedat", Value: time.Now()},
+			}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while storing tokens."})
+			return
+		}
+		if result.ModifiedCount == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the refresh token is invalid or expired."})
+			return
+		}
+
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:     models.AuditTokenRefresh,
+			ActorID:  foundUser.UserID,
+			TargetID: foundUser.UserID,
+			Details:  map[string]string{"scope": scope},
+		})
+
+		// Returns a code 200 status and the new tokens.
+		c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken, "scope": scope})
+	}
+}
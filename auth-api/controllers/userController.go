@@ -16,7 +16,6 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Creates `userCollection` variable that users `user` collection from MongoDB instance.
@@ -24,27 +23,20 @@ var userCollection *mongo.Collection = database.OpenCollection(database.Client,
 // Creates a `validator` instance used to validate `User` model.
 var validate = validator.New()
 
-// Hashes inputed string with the `bcrypt` algorithm.
-func HashPassword(password string) string {
-	// Returns the bcrypt hash of the password.
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	return string(bytes)
+// Hashes inputed string with the current `PasswordHasher` (argon2id unless configured otherwise), and returns the
+// PHC formatted hash of the password.
+func HashPassword(password string) (string, error) {
+	return helpers.HashPassword(password)
 }
 
-// Verifies if `inputPassword` matches the hashed already `providedPassword`.
+// Verifies if `inputPassword` matches the already hashed `hashedPassword`, whichever algorithm produced it.
 func VerifyPassword(inputPassword string, hashedPassword string) (bool, string) {
 	// Default (if passwords match) return values
 	check := true
 	msg := ""
 
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(inputPassword))
-	
-	// Error handling for above function.
-	if err != nil {
+	// Error handling for the password check.
+	if !helpers.CheckPassword(inputPassword, hashedPassword) {
 		msg = fmt.Sprintf("email or password is incorrect.")
 		check = false
 	}
@@ -67,14 +59,42 @@ func SignUp() gin.HandlerFunc {
 			return
 		}
 
+		// Every account starts with the least privileged user type, whatever was asked for. Admins are only made by
+		// other admins at `/users/:user_id/user-type`, or for the first one, through the one-time `/setup/admin`.
+		userType := models.RoleUser
+		user.UserType = &userType
+		// Service accounts are only created by admins at `/service-accounts`.
+		user.ServiceAccount = false
+
 		// Validates that the `user` variable from the HTTP request matches the `validate` tags of the `User` model struct.
 		if validationError := validate.Struct(user); validationError != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
 			return
 		}
 
+		// Checks the given password against the password policy and the breached password corpus.
+		// This happens before looking for existing accounts, so the outcome never depends on them.
+		if err := helpers.CurrentPasswordPolicy.Validate(*user.Password, passwordPersonalInfo(user)...); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Signs the user up to the organization of the `tenant_id` query parameter, if it is present and open to sign ups.
+		// Depending on the organization, the email must then be unique among its accounts or across the service.
+		user.TenantID = ""
+		user.Memberships = []models.OrgMembership{}
+		if orgId := c.Query("tenant_id"); orgId != "" {
+			organization, err := helpers.FindOrganization(ctx, orgId)
+			if err != nil || !organization.OpenSignUp {
+				c.JSON(http.StatusForbidden, gin.H{"error": "the organization doesn't exist or doesn't allow sign ups."})
+				return
+			}
+			user.TenantID = helpers.EmailTenant(organization)
+			user.Memberships = append(user.Memberships, models.OrgMembership{OrgID: organization.OrgID, Roles: []string{}, JoinedAt: time.Now()})
+		}
+
 		// Checks if there is an existing document in the `userCollection` with the same `email` as the `user` variable from the HTTP request.
-		countEmail, err := userCollection.CountDocuments(ctx, bson.M{"email":user.Email})
+		countEmail, err := userCollection.CountDocuments(ctx, helpers.EmailFilter(*user.Email, user.TenantID))
 		// Releases ctx (context) and the resources it uses as soon as the CountDocuments() function completes.
 		defer cancel()
 		// Error handling for the above `CountDocuments()` function.
@@ -83,6 +103,11 @@ func SignUp() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking user email."})
 		}
 		if countEmail > 0{
+			recordSignUpConflict(c, user, "email_in_use")
+			if helpers.SIGNUP_HIDE_EXISTING_ACCOUNTS {
+				respondToHiddenSignUpConflict(c, user, "Someone just tried to create an account with this email address, which is already registered.")
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "the email provided is already in use."})
 			return 
 		}
@@ -99,13 +124,24 @@ func SignUp() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking user phone number."})
 		}
 		if countPhone > 0 {
+			recordSignUpConflict(c, user, "phone_in_use")
+			if helpers.SIGNUP_HIDE_EXISTING_ACCOUNTS {
+				respondToHiddenSignUpConflict(c, user, "Your account could not be created with the details you provided.")
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "the phone number provided is already in use."})
 			return 
 		}
 
 		// Hashses the given password from the HTTP request and replaces the correlating field in `user`.
-		password := HashPassword(*user.Password)
+		password, err := HashPassword(*user.Password)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		user.Password = &password
+		user.PasswordChangedAt = time.Now()
+		user.PasswordHistory = []string{}
 
 		// Sets the time the `user` is created at in the `CreatedAt` field of the `user` object.
 		user.CreatedAt, err = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
@@ -127,8 +163,23 @@ func SignUp() gin.HandlerFunc {
 		user.ID = primitive.NewObjectID()
 		// Sets the `user` object's `UserID` field to the hex encoding of the object's `ID` field.
 		user.UserID = user.ID.Hex()
+		// Roles and groups can only be assigned by admins, never chosen at sign up.
+		user.Roles = []string{}
+		user.Groups = []string{}
+		// Computes the permissions the tokens grant, which are those of the `USER` role.
+		_, permissions, err := helpers.EffectiveAccess(ctx, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating tokens."})
+			return
+		}
+		// The tokens act within the organization the user just signed up to, if any.
+		tenant, ok := tokenTenant(c, user)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you aren't a member of this organization."})
+			return
+		}
 		// Uses the `GenerateAllTokens()` function to generate necessary tokens needed for authentication/authorization.
-		token, refreshToken, err := helpers.GenerateAllTokens(*user.Email, *user.FirstName, *user.LastName, *user.UserType, *&user.UserID)
+		token, refreshToken, err := helpers.GenerateAllTokens(*user.Email, *user.FirstName, *user.LastName, *user.UserType, *&user.UserID, helpers.ScopeAll, tenant, permissions)
 		// Error handling for above function.
 		if err != nil {
 			log.Panic(err)
@@ -141,19 +192,64 @@ func SignUp() gin.HandlerFunc {
 
 		// Inserts the `user` object into the `userCollection` and collects the resulting insertion number in `resultInsertionNumber`.
 		resultInsertionNumber, insertError := userCollection.InsertOne(ctx, user)
-		// Error handling for above function.
+		// Error handling for above function, which records the sign up as failed.
 		if insertError != nil {
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:    models.AuditSignUp,
+				Outcome: models.AuditOutcomeFailure,
+				Details: map[string]string{"reason": "insert_failed", "email": *user.Email},
+			})
 			msg := fmt.Sprintf("User item was not created")
 			c.JSON(http.StatusInternalServerError, gin.H{"error":msg})
+			return
 		}
 
 		// Releases ctx (context) and the resources it uses as soon as the InsertOne() function completes.
 		defer cancel()
+		// Records the sign up, now that the account exists.
+		helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditSignUp, ActorID: user.UserID, TargetID: user.UserID})
+
+		// Answers exactly like a sign up with an already registered email, which also sends an email.
+		if helpers.SIGNUP_HIDE_EXISTING_ACCOUNTS {
+			go sendSignUpEmail(*user.Email, "Welcome! Your account has been created and you can now sign in.")
+			c.JSON(http.StatusAccepted, signUpAcceptedResponse)
+			return
+		}
+
 		// Returns a code 200 status and the `resultInsertionNumber`.
 		c.JSON(http.StatusOK, resultInsertionNumber)
 	}
 }
 
+// Response to every sign up when `SIGNUP_HIDE_EXISTING_ACCOUNTS` is enabled.
+var signUpAcceptedResponse = gin.H{"message": "check your email to finish signing up."}
+
+// Responds to a sign up that conflicts with an existing account exactly like a successful one, emailing `body`
+// to the provided address instead of revealing the conflict.
+func respondToHiddenSignUpConflict(c *gin.Context, user models.User, body string) {
+	// Hashes the password anyway, so this takes as long as creating the account.
+	_, _ = HashPassword(*user.Password)
+
+	go sendSignUpEmail(*user.Email, body+"\n\nIf you already have an account, sign in or reset your password instead. If this wasn't you, you can ignore this email.")
+	c.JSON(http.StatusAccepted, signUpAcceptedResponse)
+}
+
+// Records a sign up refused because of an existing account.
+func recordSignUpConflict(c *gin.Context, user models.User, reason string) {
+	helpers.RecordAuditEvent(c, models.AuditEvent{
+		Type:    models.AuditSignUp,
+		Outcome: models.AuditOutcomeFailure,
+		Details: map[string]string{"reason": reason, "email": *user.Email},
+	})
+}
+
+// Sends a sign up related email, in the background so SMTP latency doesn't show in response times.
+func sendSignUpEmail(email, body string) {
+	if err := helpers.SendEmail(email, "Your sign up", body); err != nil {
+		log.Println(err)
+	}
+}
+
 // Handler function for the `/login` route.
 func Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -171,47 +267,222 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
+		// Makes sure both credentials were provided.
+		if user.Email == nil || user.Password == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error":"email and password are required."})
+			return
+		}
+
 		// Finds the document in `userCollection` that matches the `user` object's `email` field and stores the decoded verison in `foundUser`.
- 		err := userCollection.FindOne(ctx, bson.M{"email":user.Email}).Decode(&foundUser)
+		// The account is looked up in the organization of the `tenant_id` query parameter, if it is present.
+		filter, err := helpers.AccountFilter(ctx, *user.Email, c.Query("tenant_id"))
+		if err == nil {
+			err = userCollection.FindOne(ctx, filter).Decode(&foundUser)
+		}
 		// Releases ctx (context) and the resources it uses as soon as the `FindOne()` function completes.
 		defer cancel()
 		// Error handling for the above `FindOne()` function, alonside verification that the `foundUser` object is a real/valid user.
-		if err != nil || foundUser.Email == nil {
+		// A dummy password check is made first, so unknown emails take as long to answer as a wrong password.
+		if err != nil || foundUser.Email == nil || foundUser.Password == nil {
+			helpers.DummyPasswordCheck(*user.Password)
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:    models.AuditLoginFailure,
+				Outcome: models.AuditOutcomeFailure,
+				Details: map[string]string{"method": "password", "reason": "unknown_email", "email": *user.Email},
+			})
 			c.JSON(http.StatusInternalServerError, gin.H{"error":"email or password is incorrect."})
 			return
 		}
 
+		// Checks whether the account is locked after too many failed logins.
+		locked := foundUser.LockedUntil.After(time.Now())
+
 		// Uses the `VerifyPassword()` function to see if the `user` object's password field matches the same one of the `foundUser` object.
-		passwordIsValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
+		// The password is verified even for locked accounts, so they take as long to answer as any other account.
+		passwordIsValid, _ := VerifyPassword(*user.Password, *foundUser.Password)
 		// Releases ctx (context) and the resources it uses as soon as the `VerifyPassword()` function completes.
 		defer cancel()
-		// Error handling for the above `VarifyPassword()` function.
-		if passwordIsValid != true {
-			c.JSON(http.StatusInternalServerError, gin.H{"error":msg})
+		// Error handling for the above `VarifyPassword()` function. Locked accounts get the exact same response
+		// as a wrong password, so it can't be used to find out which accounts exist or are locked.
+		if passwordIsValid != true || locked {
+			reason := "locked"
+			if !locked {
+				reason = "wrong_password"
+			}
+			recordFailedLogin(ctx, foundUser, locked)
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:     models.AuditLoginFailure,
+				Outcome:  models.AuditOutcomeFailure,
+				TargetID: foundUser.UserID,
+				Details:  map[string]string{"method": "password", "reason": reason},
+			})
+			c.JSON(http.StatusInternalServerError, gin.H{"error":"email or password is incorrect."})
+			return
+		}
+
+		// Clears the failed login counters now that the password was right. For users with MFA enabled this waits until
+		// the code is right too, so that wrong codes keep counting towards the lockout whoever knows the password.
+		if !foundUser.MFAEnabled {
+			resetFailedLogins(ctx, foundUser)
+		}
+
+		// Upgrades the stored hash while the plain password is at hand, if it was produced by an older algorithm
+		// or with weaker parameters than the current policy. The stored hash is kept up to date, since the challenge of an
+		// expired password is bound to it.
+		if helpers.PasswordNeedsRehash(*foundUser.Password) {
+			hash := rehashPassword(ctx, foundUser, *user.Password)
+			foundUser.Password = &hash
+		}
+
+		// Compares the client against the user's known devices and last sign in location, asking for a
+		// stronger proof of identity when the user couldn't have travelled here since their last sign in.
+		if !checkSuspiciousLogin(c, foundUser, assessLogin(c, foundUser)) {
+			return
+		}
+
+		// Users whose password has outlived the maximum age must choose a new one at `/users/login/password-change`.
+		changedAt := foundUser.PasswordChangedAt
+		if changedAt.IsZero() {
+			changedAt = foundUser.CreatedAt
+		}
+		if helpers.PasswordExpired(changedAt) {
+			changeToken, err := helpers.GeneratePasswordChangeToken(foundUser.UserID, *foundUser.Password)
+			// Error handling for the above function.
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating tokens."})
+				return
+			}
+
+			// Returns a code 200 status and the challenge token.
+			c.JSON(http.StatusOK, gin.H{"password_change_required": true, "password_change_token": changeToken})
 			return
 		}
 
-		// Generates new tokens for the `foundUser` object with use of the `GenerateAllTokens` function.
-		token, refreshToken, err := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.FirstName, *foundUser.LastName, *foundUser.UserType, *&foundUser.UserID)
+		respondWithLogin(c, foundUser)
+	}
+}
+
+// Replaces the stored password hash of `user` with a hash of `password` produced by the current hasher, and returns
+// the hash that is stored afterwards.
+func rehashPassword(ctx context.Context, user models.User, password string) string {
+	hash, err := helpers.HashPassword(password)
+	if err != nil {
+		log.Println(err)
+		return *user.Password
+	}
+
+	// Only replaces the hash that was verified, in case the password changed in the meantime.
+	result, err := userCollection.UpdateOne(ctx,
+		bson.M{"userid": user.UserID, "password": *user.Password},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "password", Value: hash}}}},
+	)
+	if err != nil {
+		log.Println(err)
+		return *user.Password
+	}
+	if result.ModifiedCount == 0 {
+		return *user.Password
+	}
+
+	return hash
+}
+
+// Completes a first factor login of `foundUser`, responding with an MFA challenge if the user has MFA enabled.
+func respondWithLogin(c *gin.Context, foundUser models.User) {
+	// Users with MFA enabled only receive a challenge token, which must be exchanged at `/users/login/mfa`.
+	if foundUser.MFAEnabled {
+		mfaToken, err := storeMFAChallenge(foundUser.UserID)
 		// Error handling for the above function.
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating tokens."})
-			return 
+			return
 		}
 
-		// Updates all token fields of the `foundUser` email 
-		helpers.UpdatedAllTokens(token, refreshToken, foundUser.UserID)
+		// Returns a code 200 status and the challenge token.
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
+
+	respondWithTokens(c, foundUser)
+}
 
-		// Returns a code 200 status and the JSON of `foundUser`.
-		c.JSON(http.StatusOK, foundUser)
+// Returns the organization the tokens of `user` act within, which is the one of the `tenant_id` query parameter or
+// else the one the account belongs to, and whether the user is a member of it. Tokens can only act within
+// organizations the user is a member of.
+func tokenTenant(c *gin.Context, user models.User) (string, bool) {
+	tenant := c.DefaultQuery("tenant_id", user.TenantID)
+	if _, ok := helpers.FindMembership(user, tenant); tenant != "" && !ok {
+		return "", false
 	}
+
+	return tenant, true
+}
+
+// Generates new tokens for `foundUser`, stores them and responds with the JSON of `foundUser`.
+// The tokens are limited to the space separated scopes of the `scope` query parameter, if it is present, and act
+// within the organization of the `tenant_id` query parameter, or else the one the user's email belongs to.
+func respondWithTokens(c *gin.Context, foundUser models.User) {
+	scope, err := helpers.NarrowScope(helpers.ScopeAll, c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant, ok := tokenTenant(c, foundUser)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you aren't a member of this organization."})
+		return
+	}
+
+	// Computes the permissions granted by the user's roles and groups, which the access token carries.
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, permissions, err := helpers.EffectiveAccess(ctx, foundUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating tokens."})
+		return
+	}
+
+	// Generates new tokens for the `foundUser` object with use of the `GenerateAllTokens` function.
+	token, refreshToken, err := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.FirstName, *foundUser.LastName, *foundUser.UserType, *&foundUser.UserID, scope, tenant, permissions)
+	// Error handling for the above function.
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating tokens."})
+		return 
+	}
+
+	// Updates all token fields of the `foundUser` email 
+	helpers.UpdatedAllTokens(token, refreshToken, foundUser.UserID)
+	// Remembers the device and location the user signed in from.
+	recordLoginDevice(c, foundUser)
+	// Records the login, noting which route completed it.
+	helpers.RecordAuditEvent(c, models.AuditEvent{
+		Type:     models.AuditLoginSuccess,
+		ActorID:  foundUser.UserID,
+		TargetID: foundUser.UserID,
+		Details:  map[string]string{"path": c.FullPath()},
+	})
+	// Returns the freshly generated tokens rather than the ones previously stored on `foundUser`.
+	foundUser.Token = &token
+	foundUser.RefreshToken = &refreshToken
+	foundUser.MFARecoveryCodesRemaining = len(foundUser.MFARecoveryCodes)
+
+	// Returns a code 200 status and the JSON of `foundUser`.
+	c.JSON(http.StatusOK, foundUser)
+}
+
+// A page of users returned by `GetUsers()`.
+type userPage struct {
+	TotalCount int           `bson:"total_count" json:"total_count"`
+	UserItems  []models.User `bson:"user_items" json:"user_items"`
 }
 
 func GetUsers() gin.HandlerFunc {
 	return func(c *gin.Context){
-		// Uses the `CheckUserType()` to make sure that the autherization token used has a `ADMIN` user type assosiated to it's parent `user` object and handles possible errors. 
-		if err := helpers.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error":err.Error()})
+		// Tokens acting within an organization only list its members, which its admins may do.
+		tenant := c.GetString("tenant")
+		if err := helpers.Authorize(c, models.PermissionUsersRead, models.PolicyResource{Type: "user", Tenant: tenant}); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "required_permission": models.PermissionUsersRead})
 			return
 		}
 
@@ -241,9 +512,29 @@ func GetUsers() gin.HandlerFunc {
 			startIndex = (page - 1) *  recordPerPage
 		}
 
-		// Gets all documents of the collection.
+		// Gets all documents of the collection, or only the members of the organization of the token.
+		match := bson.D{}
+		if tenant != "" {
+			match = bson.D{{Key: "memberships.orgid", Value: tenant}}
+		}
 		matchStage := bson.D{
-			{Key: "$match", Value: bson.D{{}}},
+			{Key: "$match", Value: match},
+		}
+
+		// Strips the credentials and secrets from every document. The users are also decoded into the `User` model, so
+		// none of the fields it hides from JSON are ever listed.
+		unsetStage := bson.D{
+			{Key: "$project", Value: bson.D{
+				{Key: "password", Value: 0},
+				{Key: "token", Value: 0},
+				{Key: "refreshtoken", Value: 0},
+				{Key: "mfasecret", Value: 0},
+				{Key: "mfapendingsecret", Value: 0},
+				{Key: "passwordhistory", Value: 0},
+				{Key: "webauthncredentials", Value: 0},
+				{Key: "knowndevices", Value: 0},
+				{Key: "lastloginlocation", Value: 0},
+			}},
 		}
 
 		// Groups by `_id` and derives `total_count` of documents of `userCollection`.
@@ -266,21 +557,40 @@ func GetUsers() gin.HandlerFunc {
 
 		// Aggregates the `userCollection` using the above fields.
 		result, err := userCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, groupStage, projectStage,
+			matchStage, unsetStage, groupStage, projectStage,
 		})
 		// Releases ctx (context) and the resources it uses as soon as the `Aggregate()` function completes.
 		defer cancel()
 		// Error handling for the `Aggregate()` function.
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error":"error occured whilst listing user items."})
+			return
 		}
 		
 		// Initiates final return variable.
-		var allUsers []bson.M
+		var allUsers []userPage
 		
 		// Adds all results of the `Aggregate()` function to `allUsers` and handles possible errors.
 		if err = result.All(ctx, &allUsers); err != nil {
-			log.Fatal(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error":"error occured whilst listing user items."})
+			return
+		}
+
+		// Records that an admin listed the users.
+		helpers.RecordAuditEvent(c, models.AuditEvent{
+			Type:    models.AuditAdminListUsers,
+			Details: map[string]string{"page": strconv.Itoa(page), "record_per_page": strconv.Itoa(recordPerPage), "tenant": tenant},
+		})
+
+		// An organization without members yields no group at all.
+		if len(allUsers) == 0 {
+			c.JSON(http.StatusOK, userPage{UserItems: []models.User{}})
+			return
+		}
+
+		// Exposes how many recovery codes each user has left.
+		for index := range allUsers[0].UserItems {
+			allUsers[0].UserItems[index].MFARecoveryCodesRemaining = len(allUsers[0].UserItems[index].MFARecoveryCodes)
 		}
 
 		// Returns a code 200 status and the `allUsers[0]` slice.
@@ -293,8 +603,10 @@ func GetUser() gin.HandlerFunc {
 		// Retrives the `user_id` parameter from URL path.
 		userId := c.Param("user_id")
 		
-		// Makes sure that the type of user that is making the search call is an `ADMIN` if they are looking for a user other than themselves.
-		if err := helpers.MatchUserTypeToUID(c, userId); err != nil {
+		// Asks the policy whether the user making the search call may read the user, which they always may for themselves.
+		// Tokens acting within an organization can only read its members, so the user belongs to it.
+		tenant := c.GetString("tenant")
+		if err := helpers.Authorize(c, models.PermissionUsersRead, models.PolicyResource{Type: "user", ID: userId, OwnerID: userId, Tenant: tenant}); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error":err.Error()})
 			return
 		}
@@ -305,8 +617,13 @@ func GetUser() gin.HandlerFunc {
 		// Initiates the `user` variable which stores the `User` model of the found.
 		var user models.User
 
-		// Finds the user with the matching `userid` as the `userId` parameter and decodes it into the user object.
-		err := userCollection.FindOne(ctx, bson.M{"userid":userId}).Decode(&user)
+		// Finds the user with the matching `userid` as the `userId` parameter, among the members of the organization
+		// of the token if it has one, and decodes it into the user object.
+		filter := bson.M{"userid":userId}
+		if tenant != "" {
+			filter["memberships.orgid"] = tenant
+		}
+		err := userCollection.FindOne(ctx, filter).Decode(&user)
 		// Releases ctx (context) and the resources it uses as soon as the `FindOne()` function completes.
 		defer cancel()
 		// Error handling of the FindOne() funcion.
@@ -315,6 +632,14 @@ func GetUser() gin.HandlerFunc {
 			return
 		}
 
+		// Records reads of another user's record, which only users granted `users:read` can make.
+		if userId != c.GetString("user_id") {
+			helpers.RecordAuditEvent(c, models.AuditEvent{Type: models.AuditAdminReadUser, TargetID: userId})
+		}
+
+		// Exposes how many recovery codes the user has left.
+		user.MFARecoveryCodesRemaining = len(user.MFARecoveryCodes)
+
 		// Returns a code 200 status and the `users` object.
 		c.JSON(http.StatusOK, user)
 	}
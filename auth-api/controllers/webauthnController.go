@@ -0,0 +1,357 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Creates `webauthnChallengeCollection` variable that uses the `webauthn_challenge` collection from MongoDB instance,
+// where unused challenges are deleted once expired.
+var webauthnChallengeCollection *mongo.Collection = helpers.OpenWebAuthnChallengeCollection()
+
+// Body of the `/users/webauthn/register/finish` request, as produced by `navigator.credentials.create()`.
+type webauthnRegistrationRequest struct {
+	ID       string `json:"id" validate:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+		AttestationObject string `json:"attestationObject" validate:"required"`
+	} `json:"response"`
+}
+
+// Body of the `/users/login/webauthn/begin` request, the email is optional for discoverable credentials.
+type webauthnLoginBeginRequest struct {
+	Email string `json:"email"`
+}
+
+// Body of the `/users/login/webauthn/finish` request, as produced by `navigator.credentials.get()`.
+type webauthnLoginRequest struct {
+	ID       string `json:"id" validate:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+		AuthenticatorData string `json:"authenticatorData" validate:"required"`
+		Signature         string `json:"signature" validate:"required"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Handler function for the `/users/webauthn/register/begin` route.
+func BeginWebAuthnRegistration() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var user models.User
+		defer cancel()
+
+		// Finds the authenticated user using the `user_id` set by the `Authenticate()` middleware.
+		if err := userCollection.FindOne(ctx, bson.M{"userid": c.GetString("user_id")}).Decode(&user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Issues a new single-use challenge for the ceremony.
+		challenge, err := storeWebAuthnChallenge(ctx, helpers.WebAuthnCeremonyRegister, user.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the challenge."})
+			return
+		}
+
+		// Lists the credentials the user already has, so the same authenticator isn't registered twice.
+		excludeCredentials := []gin.H{}
+		for _, credential := range user.WebAuthnCredentials {
+			excludeCredentials = append(excludeCredentials, gin.H{"type": "public-key", "id": credential.CredentialID})
+		}
+
+		// Returns a code 200 status and the options for `navigator.credentials.create()`.
+		c.JSON(http.StatusOK, gin.H{"publicKey": gin.H{
+			"challenge": challenge,
+			"rp":        gin.H{"id": helpers.WEBAUTHN_RP_ID, "name": helpers.WEBAUTHN_RP_NAME},
+			"user": gin.H{
+				"id":          base64.RawURLEncoding.EncodeToString([]byte(user.UserID)),
+				"name":        *user.Email,
+				"displayName": *user.FirstName + " " + *user.LastName,
+			},
+			"pubKeyCredParams": []gin.H{
+				{"type": "public-key", "alg": helpers.COSEAlgES256},
+				{"type": "public-key", "alg": helpers.COSEAlgEdDSA},
+				{"type": "public-key", "alg": helpers.COSEAlgRS256},
+			},
+			"timeout":            helpers.WebAuthnChallengeMinutes * 60 * 1000,
+			"attestation":        "direct",
+			"excludeCredentials": excludeCredentials,
+			"authenticatorSelection": gin.H{
+				"residentKey":      "preferred",
+				"userVerification": "required",
+			},
+		}})
+	}
+}
+
+// Handler function for the `/users/webauthn/register/finish` route.
+func FinishWebAuthnRegistration() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request webauthnRegistrationRequest
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Decodes the base64url encoded fields of the response.
+		clientDataJSON, err := decodeBase64URL(request.Response.ClientDataJSON)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		attestationObject, err := decodeBase64URL(request.Response.AttestationObject)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Consumes the challenge, which must have been issued to the authenticated user.
+		challenge, err := consumeWebAuthnChallenge(ctx, helpers.WebAuthnClientDataChallenge(clientDataJSON), helpers.WebAuthnCeremonyRegister)
+		if err != nil || challenge.UserID != c.GetString("user_id") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the challenge is invalid or expired."})
+			return
+		}
+
+		// Verifies the attestation and extracts the new credential.
+		credential, err := helpers.VerifyWebAuthnRegistration(clientDataJSON, attestationObject, challenge.Challenge)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// A credential ID may only ever belong to a single user.
+		count, err := userCollection.CountDocuments(ctx, bson.M{"webauthncredentials.credentialid": credential.CredentialID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while checking the credential."})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the credential is already registered."})
+			return
+		}
+
+		// Stores the credential on the user.
+		_, err = userCollection.UpdateOne(ctx, bson.M{"userid": challenge.UserID}, bson.D{
+			{Key: "$push", Value: bson.D{{Key: "webauthncredentials", Value: credential}}},
+		})
+		// Error handling for the above `UpdateOne()` function.
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while storing the credential."})
+			return
+		}
+
+		// Returns a code 200 status and the stored credential.
+		c.JSON(http.StatusOK, credential)
+	}
+}
+
+// Handler function for the `/users/login/webauthn/begin` route.
+func BeginWebAuthnLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request webauthnLoginBeginRequest
+		var user models.User
+		defer cancel()
+
+		// Parses the `request` variable from the HTTP request, an empty body is allowed.
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// Issues a new single-use challenge for the ceremony, not yet bound to any user.
+		challenge, err := storeWebAuthnChallenge(ctx, helpers.WebAuthnCeremonyLogin, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while generating the challenge."})
+			return
+		}
+
+		// Lists the credentials of the user when an email is provided. Unknown emails get an empty list,
+		// exactly like a discoverable credential login, so the response doesn't reveal which emails exist.
+		allowCredentials := []gin.H{}
+		if request.Email != "" {
+			filter, err := helpers.AccountFilter(ctx, request.Email, c.Query("tenant_id"))
+			if err == nil {
+				err = userCollection.FindOne(ctx, filter).Decode(&user)
+			}
+			if err == nil {
+				for _, credential := range user.WebAuthnCredentials {
+					allowCredentials = append(allowCredentials, gin.H{"type": "public-key", "id": credential.CredentialID})
+				}
+			}
+		}
+
+		// Returns a code 200 status and the options for `navigator.credentials.get()`.
+		c.JSON(http.StatusOK, gin.H{"publicKey": gin.H{
+			"challenge":        challenge,
+			"rpId":             helpers.WEBAUTHN_RP_ID,
+			"timeout":          helpers.WebAuthnChallengeMinutes * 60 * 1000,
+			"allowCredentials": allowCredentials,
+			"userVerification": "required",
+		}})
+	}
+}
+
+// Handler function for the `/users/login/webauthn/finish` route.
+func FinishWebAuthnLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Creates a new context with a timeout of 100 seconds.
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var request webauthnLoginRequest
+		var foundUser models.User
+		defer cancel()
+
+		// Parses and validates the `request` variable from the HTTP request.
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if validationError := validate.Struct(request); validationError != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationError.Error()})
+			return
+		}
+
+		// Decodes the base64url encoded fields of the response.
+		clientDataJSON, err := decodeBase64URL(request.Response.ClientDataJSON)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		authenticatorData, err := decodeBase64URL(request.Response.AuthenticatorData)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		signature, err := decodeBase64URL(request.Response.Signature)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userHandle, err := decodeBase64URL(request.Response.UserHandle)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Consumes the challenge so the same assertion can never be replayed.
+		challenge, err := consumeWebAuthnChallenge(ctx, helpers.WebAuthnClientDataChallenge(clientDataJSON), helpers.WebAuthnCeremonyLogin)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the challenge is invalid or expired."})
+			return
+		}
+
+		// Finds the user the credential belongs to.
+		credentialId := strings.TrimRight(request.ID, "=")
+		err = userCollection.FindOne(ctx, bson.M{"webauthncredentials.credentialid": credentialId}).Decode(&foundUser)
+		if err != nil || (len(userHandle) > 0 && string(userHandle) != foundUser.UserID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the credential is not recognized."})
+			return
+		}
+
+		var credential models.WebAuthnCredential
+		for _, stored := range foundUser.WebAuthnCredentials {
+			if stored.CredentialID == credentialId {
+				credential = stored
+			}
+		}
+
+		// Verifies the assertion signature with the stored public key.
+		signCount, err := helpers.VerifyWebAuthnAssertion(credential, clientDataJSON, authenticatorData, signature, challenge.Challenge)
+		if err != nil {
+			helpers.RecordAuditEvent(c, models.AuditEvent{
+				Type:     models.AuditLoginFailure,
+				Outcome:  models.AuditOutcomeFailure,
+				TargetID: foundUser.UserID,
+				Details:  map[string]string{"method": "webauthn", "reason": err.Error()},
+			})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Stores the new sign count, only if no concurrent login has already moved it.
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{
+				"userid": foundUser.UserID,
+				"webauthncredentials": bson.M{"$elemMatch": bson.M{
+					"credentialid": credentialId,
+					"signcount":    credential.SignCount,
+				}},
+			},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "webauthncredentials.$.signcount", Value: signCount},
+				{Key: "webauthncredentials.$.lastusedat", Value: time.Now()},
+			}}},
+		)
+		if err != nil || result.ModifiedCount == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the credential is not recognized."})
+			return
+		}
+
+		respondWithTokens(c, foundUser)
+	}
+}
+
+// Generates a challenge for `ceremony`, stores it and returns it.
+func storeWebAuthnChallenge(ctx context.Context, ceremony, userId string) (string, error) {
+	challenge, err := helpers.GenerateWebAuthnChallenge()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = webauthnChallengeCollection.InsertOne(ctx, models.WebAuthnChallenge{
+		ID:        primitive.NewObjectID(),
+		Challenge: challenge,
+		Ceremony:  ceremony,
+		UserID:    userId,
+		ExpiresAt: time.Now().Add(time.Minute * time.Duration(helpers.WebAuthnChallengeMinutes)),
+	})
+
+	return challenge, err
+}
+
+// Deletes and returns the unexpired challenge `challenge` of `ceremony`, so that each challenge is only used once.
+func consumeWebAuthnChallenge(ctx context.Context, challenge, ceremony string) (models.WebAuthnChallenge, error) {
+	var stored models.WebAuthnChallenge
+
+	if challenge == "" {
+		return stored, errors.New("the challenge is missing")
+	}
+
+	err := webauthnChallengeCollection.FindOneAndDelete(ctx, bson.M{
+		"challenge": challenge,
+		"ceremony":  ceremony,
+		"expiresat": bson.M{"$gt": time.Now()},
+	}).Decode(&stored)
+
+	return stored, err
+}
+
+// Decodes base64url data, with or without padding.
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
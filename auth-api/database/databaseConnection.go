@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,15 +15,26 @@ import (
 
 // Creates and connects to a MongoDB instance.
 func DBInstance() *mongo.Client {
+	// The `.env` file is optional when the environment already holds the settings, as when running the tests.
 	if err := godotenv.Load(".env"); err != nil {
-	log.Fatal("Error while loading the `.env` file.")
+		log.Println("No `.env` file was loaded, using the environment.")
 	}
 
 	// Gets the MongoDB URL from the .env file.
 	MongoDB := os.Getenv("MONGODB_URL")
+	clientOptions := options.Client().ApplyURI(MongoDB)
+
+	// The URL is required, except by the tests, which use a local instance that is given up on quickly when it isn't
+	// running, so they don't wait on a database they don't need.
+	if MongoDB == "" {
+		if !runningTests() {
+			log.Fatal("The `MONGODB_URL` environment variable must be set.")
+		}
+		clientOptions = options.Client().ApplyURI("mongodb://localhost:27017").SetServerSelectionTimeout(time.Second)
+	}
 
 	// Creates a new MongoDB client
-	client, err := mongo.NewClient(options.Client().ApplyURI(MongoDB))
+	client, err := mongo.NewClient(clientOptions)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -41,6 +53,12 @@ func DBInstance() *mongo.Client {
 	return client
 } 
 
+// Returns whether the running binary was built by `go test`, whose binaries are named after their package with a
+// `.test` suffix, followed by `.exe` on Windows.
+func runningTests() bool {
+	return strings.HasSuffix(strings.TrimSuffix(os.Args[0], ".exe"), ".test")
+}
+
 // Global variable that holds a MongoDB client instance.
 var Client *mongo.Client = DBInstance()
 
@@ -0,0 +1,114 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Represents the `api_key` collection in the MongoDB database.
+var apiKeyCollection *mongo.Collection = openAPIKeyCollection()
+
+// Start of every API key, which tells them apart from JWTs and makes leaked keys easy to spot.
+const APIKeyPrefix = "ak_"
+
+// Number of days an API key stays valid for when no expiry is asked for, taken from the `API_KEY_EXPIRY_DAYS`
+// environment variable. It defaults to 90 days.
+var API_KEY_EXPIRY_DAYS int = envIntOrDefault("API_KEY_EXPIRY_DAYS", 90)
+
+// Minimum time between two updates of the last use of a key, so busy keys don't cause a write on every request.
+const apiKeyLastUsedInterval = time.Minute
+
+// Opens the `api_key` collection, making key IDs unique.
+func openAPIKeyCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "api_key")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "keyid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Generates a new API key, formatted as `ak_<key id>_<secret>`, and returns it along with its key ID and prefix.
+func GenerateAPIKey() (key, keyID, prefix string, err error) {
+	id := make([]byte, 8)
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return
+	}
+
+	keyID = hex.EncodeToString(id)
+	prefix = APIKeyPrefix + keyID
+	return prefix + "_" + secret, keyID, prefix, nil
+}
+
+// Returns whether `token` is an API key rather than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Authenticates the API key `key` used from `ip`, returning the key and the service account it belongs to. Only a
+// generic error is returned, so callers can't tell unknown, revoked and expired keys apart.
+func AuthenticateAPIKey(key, ip string) (models.APIKey, models.User, error) {
+	var apiKey models.APIKey
+	var user models.User
+	invalid := errors.New("the API key is invalid or expired")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Finds the key by the ID it embeds, then compares the hash of the whole key in constant time.
+	parts := strings.SplitN(strings.TrimPrefix(key, APIKeyPrefix), "_", 2)
+	if len(parts) != 2 {
+		return apiKey, user, invalid
+	}
+	if err := apiKeyCollection.FindOne(ctx, bson.M{"keyid": parts[0]}).Decode(&apiKey); err != nil {
+		return apiKey, user, invalid
+	}
+	if subtle.ConstantTimeCompare([]byte(HashToken(key)), []byte(apiKey.Hash)) != 1 {
+		return apiKey, user, invalid
+	}
+	now := time.Now()
+	if !apiKey.RevokedAt.IsZero() || !apiKey.ExpiresAt.After(now) {
+		return apiKey, user, invalid
+	}
+
+	if err := userCollection.FindOne(ctx, bson.M{"userid": apiKey.UserID, "serviceaccount": true}).Decode(&user); err != nil {
+		return apiKey, user, invalid
+	}
+
+	// Records the last use of the key, at most once per interval.
+	_, err := apiKeyCollection.UpdateOne(ctx,
+		bson.M{"keyid": apiKey.KeyID, "lastusedat": bson.M{"$lt": now.Add(-apiKeyLastUsedInterval)}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "lastusedat", Value: now}, {Key: "lastusedip", Value: ip}}}},
+	)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return apiKey, user, nil
+}
@@ -0,0 +1,208 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Represents the `audit_checkpoint` collection in the MongoDB database.
+var auditCheckpointCollection *mongo.Collection = database.OpenCollection(database.Client, "audit_checkpoint")
+
+// Audience of the checkpoint signatures, so they can never be used as access tokens.
+const AuditCheckpointAudience = "audit-checkpoint"
+
+// Minutes between two checkpoints, taken from the `AUDIT_CHECKPOINT_INTERVAL_MINUTES` environment variable.
+// It defaults to an hour.
+var AUDIT_CHECKPOINT_INTERVAL_MINUTES int = envIntOrDefault("AUDIT_CHECKPOINT_INTERVAL_MINUTES", 60)
+
+// Claims of a checkpoint signature.
+type AuditCheckpointClaims struct {
+	Sequence int64
+	Hash     string
+	jwt.StandardClaims
+}
+
+// Creates a checkpoint of the current head of the audit chain every `AUDIT_CHECKPOINT_INTERVAL_MINUTES` minutes,
+// until the service stops.
+func StartAuditCheckpoints() {
+	if AUDIT_CHECKPOINT_INTERVAL_MINUTES <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(AUDIT_CHECKPOINT_INTERVAL_MINUTES) * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := CreateAuditCheckpoint(); err != nil {
+				log.Printf("error occured while creating an audit checkpoint: %v", err)
+			}
+		}
+	}()
+}
+
+// Signs and stores a checkpoint of the current head of the audit chain. Nothing is stored, and nil is returned,
+// when the chain hasn't grown since the last checkpoint.
+func CreateAuditCheckpoint() (*models.AuditCheckpoint, error) {
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	head, err := auditChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if head.Sequence == 0 {
+		return nil, nil
+	}
+
+	var last models.AuditCheckpoint
+	err = auditCheckpointCollection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if last.Sequence >= head.Sequence {
+		return nil, nil
+	}
+
+	checkpoint := models.AuditCheckpoint{
+		ID:        primitive.NewObjectID(),
+		Sequence:  head.Sequence,
+		Hash:      head.Hash,
+		CreatedAt: time.Now().UTC(),
+	}
+	checkpoint.Signature, err = jwt.NewWithClaims(jwt.SigningMethodHS256, &AuditCheckpointClaims{
+		Sequence: checkpoint.Sequence,
+		Hash:     checkpoint.Hash,
+		StandardClaims: jwt.StandardClaims{
+			Audience: AuditCheckpointAudience,
+			IssuedAt: checkpoint.CreatedAt.Unix(),
+		},
+	}).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = auditCheckpointCollection.InsertOne(ctx, checkpoint); err != nil {
+		return nil, err
+	}
+
+	return &checkpoint, nil
+}
+
+// Returns an error unless the signature of `checkpoint` is valid and matches its sequence number and hash.
+func verifyAuditCheckpoint(checkpoint models.AuditCheckpoint) error {
+	claims := &AuditCheckpointClaims{}
+	_, err := jwt.ParseWithClaims(
+		checkpoint.Signature,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			return []byte(SECRET_KEY), nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if !claims.VerifyAudience(AuditCheckpointAudience, true) || claims.Sequence != checkpoint.Sequence || claims.Hash != checkpoint.Hash {
+		return errors.New("the checkpoint doesn't match its signature")
+	}
+
+	return nil
+}
+
+// Walks the audit chain from the first event, checking that sequence numbers have no gaps, that every event
+// links to the hash of the previous one, that every hash matches its event, and that the chain agrees with every
+// signed checkpoint. The report points at the first broken link.
+func VerifyAuditChain(ctx context.Context) (models.AuditChainReport, error) {
+	report := models.AuditChainReport{}
+
+	// Loads and checks the signature of every checkpoint.
+	cursor, err := auditCheckpointCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}))
+	if err != nil {
+		return report, err
+	}
+	var checkpoints []models.AuditCheckpoint
+	if err = cursor.All(ctx, &checkpoints); err != nil {
+		return report, err
+	}
+	checkpointHashes := map[int64]string{}
+	var lastCheckpoint int64
+	for _, checkpoint := range checkpoints {
+		if err := verifyAuditCheckpoint(checkpoint); err != nil {
+			report.BrokenAtSequence = checkpoint.Sequence
+			report.Reason = "the checkpoint " + checkpoint.ID.Hex() + " has an invalid signature"
+			return report, nil
+		}
+		checkpointHashes[checkpoint.Sequence] = checkpoint.Hash
+		lastCheckpoint = checkpoint.Sequence
+	}
+	report.CheckpointsChecked = len(checkpoints)
+
+	// Walks the chain in order.
+	cursor, err = auditCollection.Find(ctx, bson.M{"sequence": bson.M{"$gt": 0}}, options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}))
+	if err != nil {
+		return report, err
+	}
+	defer cursor.Close(ctx)
+
+	previousHash := models.AuditGenesisHash
+	expected := int64(1)
+	for cursor.Next(ctx) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			return report, err
+		}
+
+		reason := ""
+		hash, err := AuditEventHash(event)
+		switch {
+		case err != nil:
+			return report, err
+		case event.Sequence != expected:
+			reason = "the records " + strconv.FormatInt(expected, 10) + " to " + strconv.FormatInt(event.Sequence-1, 10) + " are missing"
+		case event.PrevHash != previousHash:
+			reason = "the record doesn't link to the previous record"
+		case event.Hash != hash:
+			reason = "the record was altered"
+		case checkpointHashes[event.Sequence] != "" && checkpointHashes[event.Sequence] != event.Hash:
+			reason = "the record doesn't match its signed checkpoint"
+		}
+		if reason != "" {
+			report.BrokenAtSequence = event.Sequence
+			report.BrokenRecordID = event.ID.Hex()
+			report.Reason = reason
+			return report, nil
+		}
+
+		report.RecordsChecked++
+		previousHash = event.Hash
+		expected++
+	}
+	if err := cursor.Err(); err != nil {
+		return report, err
+	}
+
+	// Records removed from the end of the chain are only detected through the checkpoints.
+	if lastCheckpoint >= expected {
+		report.BrokenAtSequence = expected
+		report.Reason = "the chain ends before its last signed checkpoint " + strconv.FormatInt(lastCheckpoint, 10)
+		return report, nil
+	}
+
+	report.Valid = true
+	return report, nil
+}
@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Represents the append-only `audit_log` collection in the MongoDB database.
+var auditCollection *mongo.Collection = openAuditCollection()
+
+// Serializes appends to the chain within this instance, other instances are kept out by the unique `sequence` index.
+var auditChainMutex sync.Mutex
+
+// Number of times an append is retried when another instance took the same sequence number.
+const auditAppendAttempts = 5
+
+// Opens the `audit_log` collection and makes sure sequence numbers can only be used once.
+func openAuditCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "audit_log")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Failures are logged rather than fatal, as the index may already exist. Events recorded before the chain
+	// was introduced have no sequence number and are left out of the index.
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "sequence", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"sequence": bson.M{"$exists": true},
+		}),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Records `event` for the request `c`, filling in its ID, time, client IP address and user agent.
+// The actor defaults to the authenticated user of the request. Events are only ever inserted, never updated,
+// and each one is chained to the previous one by its hash.
+func RecordAuditEvent(c *gin.Context, event models.AuditEvent) {
+	event.IP = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	if event.ActorID == "" {
+		event.ActorID = c.GetString("user_id")
+	}
+	if event.Outcome == "" {
+		event.Outcome = models.AuditOutcomeSuccess
+	}
+
+	// A failure to record the event is logged rather than failing the request.
+	if err := appendAuditEvent(event); err != nil {
+		log.Printf("error occured while recording the %s audit event: %v", event.Type, err)
+	}
+}
+
+// Appends `event` to the end of the audit chain.
+func appendAuditEvent(event models.AuditEvent) error {
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	auditChainMutex.Lock()
+	defer auditChainMutex.Unlock()
+
+	var err error
+	for attempt := 0; attempt < auditAppendAttempts; attempt++ {
+		var head models.AuditEvent
+		head, err = auditChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		event.ID = primitive.NewObjectID()
+		// MongoDB stores times to the millisecond, so the hashed time has to be too.
+		event.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+		event.Sequence = head.Sequence + 1
+		event.PrevHash = head.Hash
+		if event.Sequence == 1 {
+			event.PrevHash = models.AuditGenesisHash
+		}
+		if event.Hash, err = AuditEventHash(event); err != nil {
+			return err
+		}
+
+		// Another instance appended first when the sequence number is taken, so the head is read again.
+		if _, err = auditCollection.InsertOne(ctx, event); !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// Returns the last event of the audit chain, or an empty event if the chain is empty.
+func auditChainHead(ctx context.Context) (models.AuditEvent, error) {
+	var head models.AuditEvent
+	err := auditCollection.FindOne(ctx, bson.M{"sequence": bson.M{"$gt": 0}}, options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})).Decode(&head)
+	if err == mongo.ErrNoDocuments {
+		return models.AuditEvent{}, nil
+	}
+
+	return head, err
+}
+
+// Returns the hex encoded SHA-256 hash of every field of `event` except its ID and `Hash`.
+// The fields are encoded as JSON in a fixed order, and `Details` keys are sorted by the encoder.
+func AuditEventHash(event models.AuditEvent) (string, error) {
+	encoded, err := json.Marshal([]interface{}{
+		event.Sequence,
+		event.PrevHash,
+		event.Type,
+		event.Outcome,
+		event.ActorID,
+		event.TargetID,
+		event.IP,
+		event.UserAgent,
+		event.Details,
+		event.CreatedAt.UTC().UnixMilli(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
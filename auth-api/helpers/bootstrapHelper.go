@@ -0,0 +1,19 @@
+package helpers
+
+import (
+	"crypto/subtle"
+	"os"
+)
+
+// One-time token that promotes the first admin at `/setup/admin`, taken from the `BOOTSTRAP_ADMIN_TOKEN` environment
+// variable. The setup is disabled when it isn't set, and can only ever succeed once, even if the token stays set.
+var BOOTSTRAP_ADMIN_TOKEN string = os.Getenv("BOOTSTRAP_ADMIN_TOKEN")
+
+// Returns whether `token` is the configured bootstrap token.
+func CheckBootstrapToken(token string) bool {
+	if BOOTSTRAP_ADMIN_TOKEN == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(BOOTSTRAP_ADMIN_TOKEN)) == 1
+}
@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"errors"
+	"math"
+)
+
+// Returned whenever a CBOR item is malformed or uses a feature the decoder doesn't support.
+var errCBOR = errors.New("malformed or unsupported cbor data")
+
+// Decodes the first CBOR (RFC 8949) item of `data` and returns it alongside the remaining bytes.
+// Only the subset used by WebAuthn is supported: integers, byte and text strings, arrays, maps, tags and
+// the simple values false, true and null. Maps are returned as `map[interface{}]interface{}` whose keys are
+// either `int64` or `string`.
+func decodeCBOR(data []byte) (value interface{}, rest []byte, err error) {
+	return decodeCBORItem(data, 0)
+}
+
+// Decodes a single CBOR item, limiting how deeply arrays and maps may be nested.
+func decodeCBORItem(data []byte, depth int) (value interface{}, rest []byte, err error) {
+	if len(data) == 0 || depth > 16 {
+		return nil, nil, errCBOR
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	// Reads the argument of the item, which is either embedded in `info` or follows it.
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return nil, nil, errCBOR
+		}
+		for _, b := range data[:size] {
+			arg = arg<<8 | uint64(b)
+		}
+		data = data[size:]
+	default:
+		// Indefinite lengths are never produced by authenticators.
+		return nil, nil, errCBOR
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errCBOR
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errCBOR
+		}
+		if major == 3 {
+			return string(data[:arg]), data[arg:], nil
+		}
+		return append([]byte{}, data[:arg]...), data[arg:], nil
+	case 4:
+		array := []interface{}{}
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			if item, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			array = append(array, item)
+		}
+		return array, data, nil
+	case 5:
+		object := map[interface{}]interface{}{}
+		for i := uint64(0); i < arg; i++ {
+			var key, item interface{}
+			if key, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			if item, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			object[key] = item
+		}
+		return object, data, nil
+	case 6:
+		// Tags only add semantics to the item that follows, which is returned as is.
+		return decodeCBORItem(data, depth+1)
+	default:
+		switch arg {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		}
+		return nil, nil, errCBOR
+	}
+}
@@ -0,0 +1,56 @@
+package helpers
+
+import (
+	"net"
+
+	"github.com/kareem717/auth-api/models"
+)
+
+// Name of the long-lived cookie that identifies the device a user signs in from.
+const DeviceCookie = "device_id"
+
+// Lifetime of the device cookie, in days.
+const DeviceCookieDays = 365
+
+// Number of devices remembered per user, taken from the `MAX_KNOWN_DEVICES` environment variable.
+var MAX_KNOWN_DEVICES int = envIntOrDefault("MAX_KNOWN_DEVICES", 20)
+
+// Returns the network `ip` belongs to: its /24 for IPv4 addresses and its /48 for IPv6 addresses,
+// so a device keeps its fingerprint when its address changes within the same network.
+func IPPrefix(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+
+	if ipv4 := parsed.To4(); ipv4 != nil {
+		return (&net.IPNet{IP: ipv4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// Returns the fingerprint of a device from its user agent and the network prefix it signs in from.
+func DeviceFingerprint(userAgent, ipPrefix string) string {
+	return HashToken(userAgent + "\n" + ipPrefix)
+}
+
+// Returns the index in `devices` of the device with the device cookie hash `deviceHash`, or else with the
+// fingerprint `fingerprint`, or -1 if the device isn't known.
+func FindKnownDevice(devices []models.KnownDevice, fingerprint, deviceHash string) int {
+	if deviceHash != "" {
+		for index, device := range devices {
+			if device.DeviceHash == deviceHash {
+				return index
+			}
+		}
+	}
+
+	for index, device := range devices {
+		if device.Fingerprint == fingerprint {
+			return index
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// SMTP settings, taken from the `SMTP_HOST`, `SMTP_PORT`, `SMTP_USERNAME`, `SMTP_PASSWORD` and `SMTP_FROM` environment variables.
+var SMTP_HOST string = os.Getenv("SMTP_HOST")
+var SMTP_PORT string = envOrDefault("SMTP_PORT", "587")
+var SMTP_USERNAME string = os.Getenv("SMTP_USERNAME")
+var SMTP_PASSWORD string = os.Getenv("SMTP_PASSWORD")
+var SMTP_FROM string = envOrDefault("SMTP_FROM", "no-reply@localhost")
+
+// Whether emails are written to the log when no SMTP server is configured, enabled by setting the `EMAIL_DEV_LOG`
+// environment variable to `true`. Emails hold sign-in, password reset and invitation links, so this is only meant
+// for local development.
+var EMAIL_DEV_LOG bool = os.Getenv("EMAIL_DEV_LOG") == "true"
+
+// Sends a plain text email to `to`. When no SMTP server is configured the email isn't sent, and is only written to
+// the log if `EMAIL_DEV_LOG` is enabled.
+func SendEmail(to, subject, body string) error {
+	// Strips line breaks so header values can't be used to inject extra headers.
+	to = strings.NewReplacer("\r", "", "\n", "").Replace(to)
+	subject = strings.NewReplacer("\r", "", "\n", "").Replace(subject)
+
+	if SMTP_HOST == "" {
+		if !EMAIL_DEV_LOG {
+			return fmt.Errorf("no smtp server is configured, the email %q to %s was not sent", subject, to)
+		}
+		log.Printf("email to %s: %s\n%s", to, subject, body)
+		return nil
+	}
+
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s", SMTP_FROM, to, subject, body)
+
+	var auth smtp.Auth
+	if SMTP_USERNAME != "" {
+		auth = smtp.PlainAuth("", SMTP_USERNAME, SMTP_PASSWORD, SMTP_HOST)
+	}
+
+	return smtp.SendMail(SMTP_HOST+":"+SMTP_PORT, auth, SMTP_FROM, []string{to}, []byte(message))
+}
@@ -0,0 +1,29 @@
+package helpers
+
+import (
+	"os"
+	"sync"
+)
+
+// Whether `SignUp()` responds identically whether or not the email is already registered, emailing the owner of the
+// address instead. Enabled by setting the `SIGNUP_HIDE_EXISTING_ACCOUNTS` environment variable to `true`.
+var SIGNUP_HIDE_EXISTING_ACCOUNTS bool = os.Getenv("SIGNUP_HIDE_EXISTING_ACCOUNTS") == "true"
+
+// Hash of a random password, produced by the current hasher the first time it is needed.
+var dummyPasswordHash string
+var dummyPasswordHashOnce sync.Once
+
+// Checks `password` against a dummy hash produced by the current hasher, so that requests for unknown accounts
+// take as long as requests for existing ones. Always returns false.
+func DummyPasswordCheck(password string) bool {
+	dummyPasswordHashOnce.Do(func() {
+		random, err := GenerateRandomToken(32)
+		if err != nil {
+			random = "dummy-password"
+		}
+		dummyPasswordHash, _ = HashPassword(random)
+	})
+
+	CheckPassword(password, dummyPasswordHash)
+	return false
+}
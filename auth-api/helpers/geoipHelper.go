@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"log"
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"github.com/kareem717/auth-api/models"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Location of a MaxMind City database (e.g. `GeoLite2-City.mmdb`), taken from the `GEOIP_DATABASE_PATH` environment
+// variable. Without it, sign ins aren't located and impossible travel isn't detected.
+var GEOIP_DATABASE_PATH string = os.Getenv("GEOIP_DATABASE_PATH")
+
+// Highest plausible travel speed between two sign ins, in km/h, taken from the `IMPOSSIBLE_TRAVEL_SPEED_KMH`
+// environment variable. It defaults to the speed of a long-haul flight.
+var IMPOSSIBLE_TRAVEL_SPEED_KMH int = envIntOrDefault("IMPOSSIBLE_TRAVEL_SPEED_KMH", 1000)
+
+// Radius of the Earth, in kilometers.
+const earthRadiusKm = 6371.0
+
+// Reader of the GeoIP database, loaded once at startup, or nil if there is none.
+var geoipReader *geoip2.Reader = openGeoIPDatabase(GEOIP_DATABASE_PATH)
+
+// Opens the GeoIP database at `path`, returning nil if `path` is empty or the database can't be read.
+func openGeoIPDatabase(path string) *geoip2.Reader {
+	if path == "" {
+		return nil
+	}
+
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return reader
+}
+
+// Returns where `ip` is located, at the time `at`, or nil if there is no GeoIP database or it doesn't know the address.
+func LookupLoginLocation(ip string, at time.Time) *models.LoginLocation {
+	parsed := net.ParseIP(ip)
+	if geoipReader == nil || parsed == nil {
+		return nil
+	}
+
+	record, err := geoipReader.City(parsed)
+	if err != nil || (record.Location.Latitude == 0 && record.Location.Longitude == 0) {
+		return nil
+	}
+
+	return &models.LoginLocation{
+		Latitude:       record.Location.Latitude,
+		Longitude:      record.Location.Longitude,
+		AccuracyRadius: float64(record.Location.AccuracyRadius),
+		Country:        record.Country.IsoCode,
+		City:           record.City.Names["en"],
+		At:             at,
+	}
+}
+
+// Returns the great-circle distance between `from` and `to`, in kilometers.
+func DistanceKm(from, to models.LoginLocation) float64 {
+	lat1, lat2 := from.Latitude*math.Pi/180, to.Latitude*math.Pi/180
+	deltaLat := lat2 - lat1
+	deltaLon := (to.Longitude - from.Longitude) * math.Pi / 180
+
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Returns whether getting from `from` to `to` in the time between them would require travelling faster than
+// `IMPOSSIBLE_TRAVEL_SPEED_KMH`. The accuracy radii of both locations are given the benefit of the doubt.
+func ImpossibleTravel(from, to models.LoginLocation) bool {
+	distance := DistanceKm(from, to) - from.AccuracyRadius - to.AccuracyRadius
+	if distance <= 0 {
+		return false
+	}
+
+	hours := to.At.Sub(from.At).Hours()
+	if hours <= 0 {
+		return true
+	}
+
+	return distance/hours > float64(IMPOSSIBLE_TRAVEL_SPEED_KMH)
+}
@@ -0,0 +1,201 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Represents the `group` collection in the MongoDB database.
+var groupCollection *mongo.Collection = openGroupCollection()
+
+// Opens the `group` collection, making group IDs and names unique.
+func openGroupCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "group")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "groupid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Returns the groups `groupIDs` are members of, directly or through nested groups, including `groupIDs` themselves.
+// Each group is mapped to the path of groups leading to it from one of `groupIDs`, the shortest one if there are
+// several. Cycles, which can't be created but could be left behind by concurrent changes, are walked only once.
+func GroupAncestors(ctx context.Context, groupIDs []string) (map[string][]string, error) {
+	paths := map[string][]string{}
+	frontier := []string{}
+	for _, groupID := range groupIDs {
+		if _, seen := paths[groupID]; !seen {
+			paths[groupID] = []string{groupID}
+			frontier = append(frontier, groupID)
+		}
+	}
+
+	// Walks up one level of nesting at a time, so the first path found to a group is the shortest.
+	for len(frontier) > 0 {
+		cursor, err := groupCollection.Find(ctx, bson.M{"groupid": bson.M{"$in": frontier}}, options.Find().SetProjection(bson.M{"groupid": 1, "groups": 1}))
+		if err != nil {
+			return nil, err
+		}
+		var groups []models.Group
+		if err = cursor.All(ctx, &groups); err != nil {
+			return nil, err
+		}
+
+		frontier = []string{}
+		for _, group := range groups {
+			for _, parentID := range group.Groups {
+				if _, seen := paths[parentID]; seen {
+					continue
+				}
+				paths[parentID] = append(append([]string{}, paths[group.GroupID]...), parentID)
+				frontier = append(frontier, parentID)
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// Returns every role `user` holds and how it came to hold it: directly, through its `user_type`, or through the
+// groups it belongs to.
+func UserRoleGrants(ctx context.Context, user models.User) ([]models.RoleGrant, error) {
+	grants := []models.RoleGrant{}
+	for _, role := range user.Roles {
+		grants = append(grants, models.RoleGrant{Role: role, Source: models.RoleSourceDirect})
+	}
+	if user.UserType != nil {
+		grants = append(grants, models.RoleGrant{Role: *user.UserType, Source: models.RoleSourceUserType})
+	}
+	if len(user.Groups) == 0 {
+		return grants, nil
+	}
+
+	paths, err := GroupAncestors(ctx, user.Groups)
+	if err != nil {
+		return nil, err
+	}
+	groupIDs := make([]string, 0, len(paths))
+	for groupID := range paths {
+		groupIDs = append(groupIDs, groupID)
+	}
+
+	cursor, err := groupCollection.Find(ctx, bson.M{"groupid": bson.M{"$in": groupIDs}}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var groups []models.Group
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		for _, role := range group.Roles {
+			grants = append(grants, models.RoleGrant{Role: role, Source: models.RoleSourceGroup, GroupPath: paths[group.GroupID]})
+		}
+	}
+
+	return grants, nil
+}
+
+// Returns the names of every role `user` holds, including those inherited from its groups, and the permissions they grant.
+func EffectiveAccess(ctx context.Context, user models.User) (roles, permissions []string, err error) {
+	grants, err := UserRoleGrants(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roles = []string{}
+	for _, grant := range grants {
+		if !containsString(roles, grant.Role) {
+			roles = append(roles, grant.Role)
+		}
+	}
+
+	permissions, err = RolePermissions(ctx, roles)
+	return roles, permissions, err
+}
+
+// Returns the permissions of the roles held by `user` that grant `permission`, with how the user holds each role.
+func ExplainPermission(ctx context.Context, user models.User, permission string) ([]models.PermissionGrant, error) {
+	grants, err := UserRoleGrants(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, grant := range grants {
+		names = append(names, grant.Role)
+	}
+	cursor, err := roleCollection.Find(ctx, bson.M{"name": bson.M{"$in": names}})
+	if err != nil {
+		return nil, err
+	}
+	var roles []models.Role
+	if err = cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+
+	explanation := []models.PermissionGrant{}
+	for _, grant := range grants {
+		for _, role := range roles {
+			if role.Name != grant.Role {
+				continue
+			}
+			for _, candidate := range role.Permissions {
+				if HasPermission([]string{candidate}, permission) {
+					explanation = append(explanation, models.PermissionGrant{Permission: candidate, RoleGrant: grant})
+				}
+			}
+		}
+	}
+
+	return explanation, nil
+}
+
+// Returns the roles the members of the group `groupID` inherit, from it and every group it is a member of.
+func GroupRoles(ctx context.Context, groupID string) ([]string, error) {
+	paths, err := GroupAncestors(ctx, []string{groupID})
+	if err != nil {
+		return nil, err
+	}
+	groupIDs := make([]string, 0, len(paths))
+	for id := range paths {
+		groupIDs = append(groupIDs, id)
+	}
+
+	cursor, err := groupCollection.Find(ctx, bson.M{"groupid": bson.M{"$in": groupIDs}}, options.Find().SetProjection(bson.M{"roles": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var groups []models.Group
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	roles := []string{}
+	for _, group := range groups {
+		for _, role := range group.Roles {
+			if !containsString(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Represents the `invitation` collection in the MongoDB database.
+var invitationCollection *mongo.Collection = openInvitationCollection()
+
+// Number of hours an invitation link stays valid for, taken from the `INVITATION_EXPIRY_HOURS` environment variable.
+// It defaults to three days.
+var INVITATION_EXPIRY_HOURS int = envIntOrDefault("INVITATION_EXPIRY_HOURS", 72)
+
+// Address the invitation links point to, the token is appended as the `token` query parameter.
+var INVITATION_URL string = envOrDefault("INVITATION_URL", "http://localhost:3000/accept-invitation")
+
+// Opens the `invitation` collection, allowing a single pending invitation per email address and organization.
+func openInvitationCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "invitation")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "invitationid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			Keys:    bson.D{{Key: "orgid", Value: 1}, {Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": models.InvitationPending}),
+		},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Emails the link accepting `invitation` to the invited address, replacing the links sent before.
+func SendInvitationEmail(invitation models.Invitation, organization models.Organization) error {
+	token, err := GenerateInvitationToken(invitation.InvitationID, invitation.TokenID, invitation.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s?token=%s", INVITATION_URL, token)
+	body := fmt.Sprintf("You have been invited to join %s. Use the link below to accept the invitation, with your existing account or a new one. It expires on %s.\n\n%s\n\nIf you weren't expecting this, you can ignore this email.",
+		organization.Name, invitation.ExpiresAt.Format(time.RFC1123), link)
+
+	return SendEmail(invitation.Email, "You're invited to join "+organization.Name, body)
+}
@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Number of consecutive failed password or MFA code checks that lock an account, taken from the `LOCKOUT_THRESHOLD` environment variable.
+var LOCKOUT_THRESHOLD int = envIntOrDefault("LOCKOUT_THRESHOLD", 5)
+
+// Duration of the first lockout, every following lockout lasts twice as long as the previous one.
+const LockoutBaseDuration = 5 * time.Minute
+
+// Longest an account is ever locked for.
+const LockoutMaxDuration = 24 * time.Hour
+
+// Returns how long an account is locked for, given how many times it was already locked since its last successful login.
+func LockoutDuration(previousLockouts int) time.Duration {
+	duration := LockoutBaseDuration
+	for i := 0; i < previousLockouts && duration < LockoutMaxDuration; i++ {
+		duration *= 2
+	}
+
+	if duration > LockoutMaxDuration {
+		duration = LockoutMaxDuration
+	}
+
+	return duration
+}
+
+// Returns the value of the environment variable `key` as an integer, or `fallback` if it isn't set or isn't a positive integer.
+func envIntOrDefault(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 1 {
+		return fallback
+	}
+
+	return value
+}
@@ -0,0 +1,182 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Represents the `oauth_device_code` collection in the MongoDB database.
+var oauthDeviceCodeCollection *mongo.Collection = openOAuthDeviceCodeCollection()
+
+// Number of minutes a device code can be approved and redeemed in.
+const DeviceCodeMinutes = 10
+
+// Number of seconds devices wait between polls at first, and by how much the wait grows when they poll too fast.
+const DeviceCodeInterval = 5
+
+// Characters of the user codes, which leave out vowels and look-alike letters so they are easy to type and never spell words.
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// Length of the user codes, without their dash.
+const userCodeLength = 8
+
+// Address of the page where signed in users enter the user code shown on their device.
+var OAUTH_DEVICE_URL string = envOrDefault("OAUTH_DEVICE_URL", "http://localhost:3000/device")
+
+// Opens the `oauth_device_code` collection, whose device and user codes are unique, and deletes expired authorizations.
+func openOAuthDeviceCodeCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "oauth_device_code")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "devicecodehash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "usercode", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Returns `userCode` as users may type it, in upper case and without its dash or spaces.
+func NormalizeUserCode(userCode string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(userCode))
+}
+
+// Returns `userCode` with a dash in its middle, the way it is shown to users.
+func FormatUserCode(userCode string) string {
+	return userCode[:len(userCode)/2] + "-" + userCode[len(userCode)/2:]
+}
+
+// Generates a random user code.
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[index.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// Stores a new pending authorization of `clientID` for `scope`, and returns its device code and user code.
+func CreateDeviceCode(ctx context.Context, clientID, scope string) (deviceCode, userCode string, err error) {
+	if deviceCode, err = GenerateRandomToken(32); err != nil {
+		return "", "", err
+	}
+
+	now := time.Now().UTC()
+	authorization := models.OAuthDeviceCode{
+		DeviceCodeHash: HashToken(deviceCode),
+		ClientID:       clientID,
+		Scope:          scope,
+		Status:         models.DeviceCodePending,
+		Interval:       DeviceCodeInterval,
+		ExpiresAt:      now.Add(time.Minute * time.Duration(DeviceCodeMinutes)),
+		CreatedAt:      now,
+	}
+
+	// Draws another user code in the unlikely case it is already in use.
+	for attempt := 0; attempt < 3; attempt++ {
+		if authorization.UserCode, err = generateUserCode(); err != nil {
+			return "", "", err
+		}
+		authorization.ID = primitive.NewObjectID()
+		if _, err = oauthDeviceCodeCollection.InsertOne(ctx, authorization); !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return deviceCode, authorization.UserCode, nil
+}
+
+// Finds the pending authorization of `userCode` that hasn't expired yet.
+func FindPendingDeviceCode(ctx context.Context, userCode string) (authorization models.OAuthDeviceCode, err error) {
+	err = oauthDeviceCodeCollection.FindOne(ctx, bson.M{
+		"usercode":  NormalizeUserCode(userCode),
+		"status":    models.DeviceCodePending,
+		"expiresat": bson.M{"$gt": time.Now()},
+	}).Decode(&authorization)
+
+	return authorization, err
+}
+
+// Records that `userID` approved or denied the pending authorization of `userCode`, for `scope` within the
+// organization `tenant`. Each authorization can only be decided once.
+func DecideDeviceCode(ctx context.Context, userCode, userID, scope, tenant string, approve bool) error {
+	status := models.DeviceCodeDenied
+	if approve {
+		status = models.DeviceCodeApproved
+	}
+
+	result, err := oauthDeviceCodeCollection.UpdateOne(ctx, bson.M{
+		"usercode":  NormalizeUserCode(userCode),
+		"status":    models.DeviceCodePending,
+		"expiresat": bson.M{"$gt": time.Now()},
+	}, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: status},
+		{Key: "userid", Value: userID},
+		{Key: "scope", Value: scope},
+		{Key: "tenant", Value: tenant},
+	}}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("the code is invalid or expired")
+	}
+
+	return nil
+}
+
+// Polls the authorization of `deviceCode` issued to `clientID`. Devices polling faster than their interval are told
+// to slow down, which grows it, and decided authorizations are deleted, so they can only be redeemed once.
+func PollDeviceCode(ctx context.Context, deviceCode, clientID string) (authorization models.OAuthDeviceCode, slowDown bool, err error) {
+	filter := bson.M{"devicecodehash": HashToken(deviceCode), "clientid": clientID}
+	if err = oauthDeviceCodeCollection.FindOne(ctx, filter).Decode(&authorization); err != nil {
+		return authorization, false, err
+	}
+	now := time.Now()
+	if now.After(authorization.ExpiresAt) {
+		return authorization, false, nil
+	}
+
+	if now.Before(authorization.LastPolledAt.Add(time.Second * time.Duration(authorization.Interval))) {
+		_, err = oauthDeviceCodeCollection.UpdateOne(ctx, filter, bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "interval", Value: DeviceCodeInterval}}},
+			{Key: "$set", Value: bson.D{{Key: "lastpolledat", Value: now}}},
+		})
+		return authorization, true, err
+	}
+
+	if authorization.Status == models.DeviceCodePending {
+		_, err = oauthDeviceCodeCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bson.D{{Key: "lastpolledat", Value: now}}}})
+		return authorization, false, err
+	}
+	err = oauthDeviceCodeCollection.FindOneAndDelete(ctx, bson.M{"_id": authorization.ID, "status": authorization.Status}).Decode(&authorization)
+
+	return authorization, false, err
+}
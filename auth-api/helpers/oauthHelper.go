@@ -0,0 +1,406 @@
+package helpers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Represents the `oauth_client` collection in the MongoDB database.
+var oauthClientCollection *mongo.Collection = openOAuthCollection("oauth_client", bson.D{{Key: "clientid", Value: 1}}, false)
+
+// Represents the `oauth_code` collection in the MongoDB database.
+var oauthCodeCollection *mongo.Collection = openOAuthCollection("oauth_code", bson.D{{Key: "codehash", Value: 1}}, true)
+
+// Represents the `oauth_consent` collection in the MongoDB database.
+var oauthConsentCollection *mongo.Collection = openOAuthCollection("oauth_consent", bson.D{{Key: "userid", Value: 1}, {Key: "clientid", Value: 1}}, false)
+
+// Represents the `oauth_grant` collection in the MongoDB database.
+var oauthGrantCollection *mongo.Collection = openOAuthCollection("oauth_grant", bson.D{{Key: "userid", Value: 1}, {Key: "clientid", Value: 1}}, false)
+
+// Represents the `oauth_assertion` collection in the MongoDB database, which remembers the IDs of the client
+// assertions that were used until they expire.
+var oauthAssertionCollection *mongo.Collection = openOAuthCollection("oauth_assertion", bson.D{{Key: "clientid", Value: 1}, {Key: "jti", Value: 1}}, true)
+
+// Number of minutes an authorization code can be exchanged for tokens.
+const OAuthCodeMinutes = 10
+
+// Number of minutes the access tokens issued to clients acting on their own behalf stay valid for.
+const OAuthClientTokenMinutes = 60
+
+// Maximum number of minutes a client assertion can be valid for, which bounds how long its ID is remembered.
+const OAuthAssertionMaxMinutes = 60
+
+// Type of the client assertions of `private_key_jwt` clients.
+const OAuthClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// Address identifying this server as the issuer of OAuth tokens, which client assertions are addressed to.
+var OAUTH_ISSUER string = strings.TrimSuffix(envOrDefault("OAUTH_ISSUER", "http://localhost:8000"), "/")
+
+// Opens the OAuth collection `name`, making `keys` unique, and deleting its documents once expired if `expires`.
+func openOAuthCollection(name string, keys bson.D, expires bool) *mongo.Collection {
+	collection := database.OpenCollection(database.Client, name)
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{{Keys: keys, Options: options.Index().SetUnique(true)}}
+	if expires {
+		indexes = append(indexes, mongo.IndexModel{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)})
+	}
+	if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Finds the OAuth client identified by `clientID`.
+func FindOAuthClient(ctx context.Context, clientID string) (client models.OAuthClient, err error) {
+	err = oauthClientCollection.FindOne(ctx, bson.M{"clientid": clientID}).Decode(&client)
+	return client, err
+}
+
+// Returns an error unless `redirectURI` can be registered: an absolute address without a fragment, using HTTPS,
+// plain HTTP on the loopback interface, or a private scheme of a native app.
+func ValidateRedirectURI(redirectURI string) error {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil || parsed.Scheme == "" || parsed.Fragment != "" {
+		return errors.New("the redirect URI " + redirectURI + " must be absolute and without a fragment")
+	}
+	if scheme := strings.ToLower(parsed.Scheme); scheme == "javascript" || scheme == "data" || scheme == "file" {
+		return errors.New("the redirect URI " + redirectURI + " uses a forbidden scheme")
+	}
+	if parsed.Scheme == "http" {
+		if ip := net.ParseIP(parsed.Hostname()); parsed.Hostname() != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return errors.New("the redirect URI " + redirectURI + " must use HTTPS")
+		}
+	}
+	if (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host == "" {
+		return errors.New("the redirect URI " + redirectURI + " has no host")
+	}
+
+	return nil
+}
+
+// Returns the redirect URI of `client` an authorization request for `requested` is answered at. It must match a
+// registered one exactly, and can only be omitted when a single one is registered.
+func OAuthRedirectURI(client models.OAuthClient, requested string) (string, bool) {
+	if requested == "" {
+		if len(client.RedirectURIs) == 1 {
+			return client.RedirectURIs[0], true
+		}
+		return "", false
+	}
+
+	return requested, containsString(client.RedirectURIs, requested)
+}
+
+// Returns whether `secret` is the secret of the `client_secret_basic` client `client`.
+func CheckOAuthClientSecret(client models.OAuthClient, secret string) bool {
+	if OAuthClientAuthMethod(client) != models.OAuthAuthClientSecret || client.SecretHash == "" || secret == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(HashToken(secret)), []byte(client.SecretHash)) == 1
+}
+
+// Returns whether the PKCE `verifier` matches the S256 `challenge`.
+func VerifyPKCE(verifier, challenge string) bool {
+	// Verifiers are between 43 and 128 characters long.
+	if len(verifier) < 43 || len(verifier) > 128 || challenge == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+// Stores a new authorization code with the details of the authorization it was issued for, and returns it.
+func CreateOAuthCode(ctx context.Context, authorization models.OAuthCode) (string, error) {
+	code, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	authorization.CodeHash = HashToken(code)
+	authorization.CreatedAt = time.Now().UTC()
+	authorization.ExpiresAt = authorization.CreatedAt.Add(time.Minute * time.Duration(OAuthCodeMinutes))
+	if _, err := oauthCodeCollection.InsertOne(ctx, authorization); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Deletes and returns the authorization code `code`, so it can only be redeemed once, if it hasn't expired yet.
+func RedeemOAuthCode(ctx context.Context, code string) (authorization models.OAuthCode, err error) {
+	err = oauthCodeCollection.FindOneAndDelete(ctx, bson.M{
+		"codehash":  HashToken(code),
+		"expiresat": bson.M{"$gt": time.Now()},
+	}).Decode(&authorization)
+
+	return authorization, err
+}
+
+// Returns whether `userID` already allowed `clientID` to request every one of `scopes`.
+func HasOAuthConsent(ctx context.Context, userID, clientID string, scopes []string) bool {
+	var consent models.OAuthConsent
+	if err := oauthConsentCollection.FindOne(ctx, bson.M{"userid": userID, "clientid": clientID}).Decode(&consent); err != nil {
+		return false
+	}
+
+	return ScopeCovers(consent.Scopes, scopes)
+}
+
+// Records that `userID` allowed `clientID` to request `scopes`, on top of the scopes it allowed before.
+func GrantOAuthConsent(ctx context.Context, userID, clientID string, scopes []string) error {
+	now := time.Now().UTC()
+	_, err := oauthConsentCollection.UpdateOne(ctx, bson.M{"userid": userID, "clientid": clientID}, bson.D{
+		{Key: "$addToSet", Value: bson.D{{Key: "scopes", Value: bson.D{{Key: "$each", Value: scopes}}}}},
+		{Key: "$set", Value: bson.D{{Key: "updatedat", Value: now}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "createdat", Value: now}}},
+	}, options.Update().SetUpsert(true))
+
+	return err
+}
+
+// Returns `redirectURI` with `params` added to its query, to send the user back to the client with.
+func OAuthRedirect(redirectURI string, params url.Values) string {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := parsed.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+// Returns whether `client` may use the grant `grantType`. Clients registered without grants only use authorization codes.
+func OAuthClientAllowsGrant(client models.OAuthClient, grantType string) bool {
+	if len(client.GrantTypes) == 0 {
+		return grantType == models.OAuthGrantAuthorizationCode
+	}
+
+	return containsString(client.GrantTypes, grantType)
+}
+
+// Returns how `client` authenticates at the token endpoint, defaulting to its secret for confidential clients.
+func OAuthClientAuthMethod(client models.OAuthClient) string {
+	if client.TokenEndpointAuthMethod != "" {
+		return client.TokenEndpointAuthMethod
+	}
+	if client.Type == models.OAuthClientConfidential {
+		return models.OAuthAuthClientSecret
+	}
+
+	return models.OAuthAuthNone
+}
+
+// Parses the PEM encoded RSA or ECDSA public key of a `private_key_jwt` client.
+func ParseOAuthPublicKey(publicKey string) (interface{}, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKey)); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM([]byte(publicKey)); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("the public key must be a PEM encoded RSA or ECDSA public key")
+}
+
+// Audience of a client assertion, which can be a single address or a list of them.
+type oauthAudience []string
+
+// Decodes an audience given either as a string or as a list of strings.
+func (audience *oauthAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*audience = oauthAudience{single}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(audience))
+}
+
+// Represents the claims of the JWT assertion a `private_key_jwt` client authenticates with.
+type clientAssertionClaims struct {
+	Audience oauthAudience `json:"aud"`
+	jwt.StandardClaims
+}
+
+// Returns the client ID a client assertion claims to be issued by, without verifying it.
+func OAuthAssertionClientID(assertion string) string {
+	claims := &clientAssertionClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(assertion, claims); err != nil {
+		return ""
+	}
+
+	return claims.Issuer
+}
+
+// Verifies that `assertion` was signed by the `private_key_jwt` client `client` for this server, and that it wasn't
+// used before.
+func VerifyClientAssertion(ctx context.Context, client models.OAuthClient, assertion string) error {
+	publicKey, err := ParseOAuthPublicKey(client.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	// Parses the assertion, only accepting the algorithms of the client's key.
+	claims := &clientAssertionClaims{}
+	_, err = jwt.ParseWithClaims(assertion, claims, func(token *jwt.Token) (interface{}, error) {
+		switch publicKey.(type) {
+		case *rsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); ok {
+				return publicKey, nil
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); ok {
+				return publicKey, nil
+			}
+		}
+		return nil, errors.New("unexpected signing method")
+	})
+	if err != nil {
+		return err
+	}
+
+	// The client must have issued the assertion about itself, to this server, for a short time and only once.
+	if claims.Issuer != client.ClientID || claims.Subject != client.ClientID {
+		return errors.New("the assertion wasn't issued by the client")
+	}
+	if !containsString(claims.Audience, OAUTH_ISSUER) && !containsString(claims.Audience, OAUTH_ISSUER+"/oauth/token") {
+		return errors.New("the assertion isn't addressed to this server")
+	}
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	if claims.ExpiresAt == 0 || time.Until(expiresAt) > time.Minute*time.Duration(OAuthAssertionMaxMinutes) {
+		return errors.New("the assertion must expire within " + strconv.Itoa(OAuthAssertionMaxMinutes) + " minutes")
+	}
+	if claims.Id == "" {
+		return errors.New("the assertion has no ID")
+	}
+	_, err = oauthAssertionCollection.InsertOne(ctx, bson.M{"clientid": client.ClientID, "jti": claims.Id, "expiresat": expiresAt})
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.New("the assertion was already used")
+	}
+
+	return err
+}
+
+// Records `refreshToken` as the refresh token `clientID` holds on behalf of `userID`, for `scope` within the
+// organization `tenant`. When it replaces `previousRefreshToken`, that one must still be current, so each refresh token
+// can only be redeemed once.
+func StoreOAuthGrant(ctx context.Context, userID, clientID, scope, tenant, refreshToken, previousRefreshToken string) error {
+	now := time.Now().UTC()
+	filter := bson.M{"userid": userID, "clientid": clientID}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "refreshtokenhash", Value: HashToken(refreshToken)},
+		{Key: "scope", Value: scope},
+		{Key: "tenant", Value: tenant},
+		{Key: "updatedat", Value: now},
+	}}}
+
+	if previousRefreshToken != "" {
+		filter["refreshtokenhash"] = HashToken(previousRefreshToken)
+		result, err := oauthGrantCollection.UpdateOne(ctx, filter, update)
+		if err == nil && result.MatchedCount == 0 {
+			err = errors.New("the refresh token was already used")
+		}
+		return err
+	}
+
+	update = append(update, bson.E{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "createdat", Value: now}}})
+	_, err := oauthGrantCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+
+	return err
+}
+
+// Finds the grant of `clientID` whose current refresh token is `refreshToken`.
+func FindOAuthGrant(ctx context.Context, clientID, refreshToken string) (grant models.OAuthGrant, err error) {
+	err = oauthGrantCollection.FindOne(ctx, bson.M{"clientid": clientID, "refreshtokenhash": HashToken(refreshToken)}).Decode(&grant)
+	return grant, err
+}
+
+// Represents the claims of the access tokens issued to OAuth clients acting on their own behalf.
+type ClientTokenClaims struct {
+	// Space separated scopes the token is limited to.
+	Scope string `json:"scope"`
+	jwt.StandardClaims
+}
+
+// Generates an access token for the OAuth client `clientID` acting on its own behalf, limited to `scope`, whose
+// subject is the client ID. It is signed like the ID tokens, so other services can verify it with the published key
+// set without holding the secret that signs the users' tokens.
+func GenerateClientToken(clientID, scope string, minutes int) (string, error) {
+	claims := &ClientTokenClaims{
+		Scope: scope,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   clientID,
+			Audience:  ClientAudience,
+			Issuer:    OAUTH_ISSUER,
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(time.Minute * time.Duration(minutes)).Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = oidcKeyID
+
+	return token.SignedString(oidcSigningKey)
+}
+
+// Validates an access token issued to an OAuth client acting on its own behalf, and returns its claims.
+func ValidateClientToken(signedToken string) (*ClientTokenClaims, error) {
+	claims := &ClientTokenClaims{}
+	_, err := jwt.ParseWithClaims(signedToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return &oidcSigningKey.PublicKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !claims.VerifyAudience(ClientAudience, true) || !claims.VerifyIssuer(OAUTH_ISSUER, true) || claims.Subject == "" {
+		return nil, errors.New("the token isn't a client token")
+	}
+
+	return claims, nil
+}
+
+// Returns the scope to issue to `client` acting on its own behalf or through a device, for a request of `requested`.
+// It defaults to every scope of the client, and can't exceed them.
+func OAuthClientScope(client models.OAuthClient, requested string) (string, error) {
+	return NarrowScope(strings.Join(client.Scopes, " "), requested)
+}
@@ -0,0 +1,91 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Tests the scope issued to clients acting on their own behalf, which can only be narrowed.
+func TestOAuthClientScope(t *testing.T) {
+	client := models.OAuthClient{ClientID: "client-1", Scopes: []string{models.PermissionUsersRead, models.PermissionUsersWrite}}
+
+	tests := []struct {
+		requested string
+		scope     string
+		valid     bool
+	}{
+		{"", "users:read users:write", true},
+		{"users:read", "users:read", true},
+		{"users:write users:read", "users:write users:read", true},
+		{"roles:read", "", false},
+		{"users:*", "", false},
+		{"*", "", false},
+		{"not a scope", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.requested, func(t *testing.T) {
+			scope, err := OAuthClientScope(client, test.requested)
+			if (err == nil) != test.valid || scope != test.scope {
+				t.Fatalf("got %q (%v), want %q", scope, err, test.scope)
+			}
+		})
+	}
+}
+
+// Tests that client tokens are issued to the client, verify with the published key, and aren't user access tokens.
+func TestGenerateClientToken(t *testing.T) {
+	scope, err := OAuthClientScope(models.OAuthClient{Scopes: []string{models.PermissionUsersRead, models.PermissionAuditRead}}, "audit:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := GenerateClientToken("client-1", scope, OAuthClientTokenMinutes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ValidateClientToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "client-1" || claims.Scope != "audit:read" || claims.Issuer != OAUTH_ISSUER {
+		t.Fatalf("got subject %q, scope %q and issuer %q", claims.Subject, claims.Scope, claims.Issuer)
+	}
+
+	// Other services verify the token with the key of the published key set.
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["kid"] != OpenIDKeySet()["keys"].([]map[string]string)[0]["kid"] {
+			t.Errorf("the token's key ID %v isn't published", token.Header["kid"])
+		}
+		return &oidcSigningKey.PublicKey, nil
+	})
+	if err != nil || parsed.Method != jwt.SigningMethodRS256 {
+		t.Fatalf("got %v signed with %v", err, parsed.Method)
+	}
+
+	// The token isn't accepted where the users' access tokens are.
+	if _, msg := ValidateToken(token); msg == "" {
+		t.Fatal("a client token was accepted as a user access token")
+	}
+
+	// User tokens and tokens signed with another key aren't accepted as client tokens.
+	userToken, _, err := GenerateAllTokens("a@example.com", "Ada", "Lovelace", models.RoleUser, "user-1", ScopeAll, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateClientToken(userToken); err == nil {
+		t.Fatal("a user access token was accepted as a client token")
+	}
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &ClientTokenClaims{
+		Scope:          "*",
+		StandardClaims: jwt.StandardClaims{Subject: "client-1", Audience: ClientAudience, Issuer: OAUTH_ISSUER},
+	}).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateClientToken(forged); err == nil {
+		t.Fatal("a token signed with the users' secret was accepted as a client token")
+	}
+}
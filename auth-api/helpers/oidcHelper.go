@@ -0,0 +1,127 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Number of minutes the ID tokens stay valid for.
+const IDTokenMinutes = 60
+
+// Address of the page users are sent to by OpenID Connect clients, which signs them in and calls `/oauth/authorize`.
+var OAUTH_AUTHORIZATION_URL string = envOrDefault("OAUTH_AUTHORIZATION_URL", "http://localhost:3000/authorize")
+
+// RSA key signing the ID tokens, so clients can verify them with the published public key, and its key ID.
+var oidcSigningKey, oidcKeyID = loadOIDCSigningKey()
+
+// Loads the PEM encoded RSA private key of `OIDC_SIGNING_KEY`. Without one, a key is generated at startup, whose ID
+// tokens stop verifying once the server restarts and aren't shared between instances.
+func loadOIDCSigningKey() (*rsa.PrivateKey, string) {
+	var key *rsa.PrivateKey
+	var err error
+	if pem := os.Getenv("OIDC_SIGNING_KEY"); pem != "" {
+		key, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(pem))
+	} else {
+		log.Println("OIDC_SIGNING_KEY isn't set, ID tokens are signed with a temporary key.")
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return key, rsaThumbprint(&key.PublicKey)
+}
+
+// Returns the RFC 7638 thumbprint of `key`, which identifies it in the published key set.
+func rsaThumbprint(key *rsa.PublicKey) string {
+	jwk := `{"e":"` + base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()) +
+		`","kty":"RSA","n":"` + base64.RawURLEncoding.EncodeToString(key.N.Bytes()) + `"}`
+	sum := sha256.Sum256([]byte(jwk))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Returns the JSON Web Key Set holding the public key verifying the ID tokens.
+func OpenIDKeySet() map[string]interface{} {
+	key := oidcSigningKey.PublicKey
+
+	return map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": jwt.SigningMethodRS256.Alg(),
+		"kid": oidcKeyID,
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+}
+
+// Returns whether `scopes` ask for an ID token, which is only issued when the `openid` scope is asked for explicitly.
+func RequestsIDToken(scopes []string) bool {
+	return containsString(scopes, models.ScopeOpenID)
+}
+
+// Returns the standard claims about `user` that `scopes` allow to be told, which always include its subject.
+func OpenIDClaims(user models.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": user.UserID}
+
+	if containsString(scopes, models.ScopeProfile) || containsString(scopes, ScopeAll) {
+		names := []string{}
+		if user.FirstName != nil {
+			claims["given_name"] = *user.FirstName
+			names = append(names, *user.FirstName)
+		}
+		if user.LastName != nil {
+			claims["family_name"] = *user.LastName
+			names = append(names, *user.LastName)
+		}
+		claims["name"] = strings.Join(names, " ")
+		if !user.UpdatedAt.IsZero() {
+			claims["updated_at"] = user.UpdatedAt.Unix()
+		}
+	}
+	// The service doesn't verify email addresses or phone numbers, so they are never claimed as verified.
+	if (containsString(scopes, models.ScopeEmail) || containsString(scopes, ScopeAll)) && user.Email != nil {
+		claims["email"] = *user.Email
+		claims["email_verified"] = false
+	}
+	if (containsString(scopes, models.ScopePhone) || containsString(scopes, ScopeAll)) && user.Phone != nil && *user.Phone != "" {
+		claims["phone_number"] = *user.Phone
+		claims["phone_number_verified"] = false
+	}
+
+	return claims
+}
+
+// Generates the ID token telling `clientID` who `user` is, with the claims `scopes` allow. It repeats the client's
+// `nonce`, and binds the `accessToken` issued along with it through its hash.
+func GenerateIDToken(user models.User, clientID, nonce string, scopes []string, accessToken string) (string, error) {
+	claims := jwt.MapClaims(OpenIDClaims(user, scopes))
+	claims["iss"] = OAUTH_ISSUER
+	claims["aud"] = clientID
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(time.Minute * time.Duration(IDTokenMinutes)).Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	// The hash is the left half of the SHA-256 digest of the access token, as the ID token is signed with RS256.
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["at_hash"] = base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = oidcKeyID
+
+	return token.SignedString(oidcSigningKey)
+}
@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Returns the public key of the published key set, as clients rebuild it from its JWK.
+func publishedOpenIDKey(t *testing.T) (*rsa.PublicKey, string) {
+	keys := OpenIDKeySet()["keys"].([]map[string]string)
+	if len(keys) != 1 {
+		t.Fatalf("got %d published keys, want 1", len(keys))
+	}
+	key := keys[0]
+	if key["kty"] != "RSA" || key["use"] != "sig" || key["alg"] != "RS256" {
+		t.Fatalf("got key type %q, use %q and algorithm %q", key["kty"], key["use"], key["alg"])
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(key["n"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key["e"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, key["kid"]
+}
+
+// Tests that the published key is the signing key, identified by its thumbprint.
+func TestOpenIDKeySet(t *testing.T) {
+	publicKey, keyID := publishedOpenIDKey(t)
+	if !publicKey.Equal(&oidcSigningKey.PublicKey) {
+		t.Fatal("the published key isn't the signing key")
+	}
+	if keyID != oidcKeyID || keyID != rsaThumbprint(publicKey) {
+		t.Fatalf("got key ID %q, want the thumbprint %q", keyID, rsaThumbprint(publicKey))
+	}
+}
+
+// Tests the claims of ID tokens, and that they verify with the published key.
+func TestGenerateIDToken(t *testing.T) {
+	firstName, lastName, email := "Ada", "Lovelace", "ada@example.com"
+	user := models.User{UserID: "user-1", FirstName: &firstName, LastName: &lastName, Email: &email}
+
+	// The access token and its hash are the example of the OpenID Connect specification.
+	idToken, err := GenerateIDToken(user, "client-1", "n-0S6_WzA2Mj", []string{models.ScopeOpenID, models.ScopeEmail}, "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, keyID := publishedOpenIDKey(t)
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["kid"] != keyID {
+			t.Errorf("got key ID %v, want %q", token.Header["kid"], keyID)
+		}
+		return publicKey, nil
+	})
+	if err != nil || token.Method != jwt.SigningMethodRS256 {
+		t.Fatalf("got %v signed with %v", err, token.Method)
+	}
+
+	want := map[string]interface{}{
+		"sub":            "user-1",
+		"iss":            OAUTH_ISSUER,
+		"aud":            "client-1",
+		"nonce":          "n-0S6_WzA2Mj",
+		"at_hash":        "77QmUPtjPfzWtF2AnpK9RQ",
+		"email":          email,
+		"email_verified": false,
+	}
+	for name, value := range want {
+		if claims[name] != value {
+			t.Errorf("got %s %v, want %v", name, claims[name], value)
+		}
+	}
+	if _, ok := claims["name"]; ok {
+		t.Error("the profile was claimed without the profile scope")
+	}
+	if expiresAt, _ := claims["exp"].(float64); time.Until(time.Unix(int64(expiresAt), 0)) > time.Minute*time.Duration(IDTokenMinutes) {
+		t.Errorf("got expiry %v", claims["exp"])
+	}
+
+	// Without a nonce or an access token, neither is claimed.
+	idToken, err = GenerateIDToken(user, "client-1", "", []string{models.ScopeOpenID}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims = jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(idToken, claims, func(*jwt.Token) (interface{}, error) { return publicKey, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := claims["nonce"]; ok {
+		t.Error("got a nonce that wasn't asked for")
+	}
+	if _, ok := claims["at_hash"]; ok {
+		t.Error("got an access token hash without an access token")
+	}
+}
+
+// Tests that the claims about users are filtered by the scopes.
+func TestOpenIDClaims(t *testing.T) {
+	firstName, lastName, email, phone := "Ada", "Lovelace", "ada@example.com", "+15555550100"
+	updatedAt := time.Unix(1700000000, 0)
+	user := models.User{UserID: "user-1", FirstName: &firstName, LastName: &lastName, Email: &email, Phone: &phone, UpdatedAt: updatedAt}
+
+	profile := map[string]interface{}{"given_name": firstName, "family_name": lastName, "name": "Ada Lovelace", "updated_at": updatedAt.Unix()}
+	emailClaims := map[string]interface{}{"email": email, "email_verified": false}
+	phoneClaims := map[string]interface{}{"phone_number": phone, "phone_number_verified": false}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		claims []map[string]interface{}
+	}{
+		{"openid", []string{models.ScopeOpenID}, nil},
+		{"profile", []string{models.ScopeOpenID, models.ScopeProfile}, []map[string]interface{}{profile}},
+		{"email", []string{models.ScopeOpenID, models.ScopeEmail}, []map[string]interface{}{emailClaims}},
+		{"phone", []string{models.ScopeOpenID, models.ScopePhone}, []map[string]interface{}{phoneClaims}},
+		{"every scope", []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail, models.ScopePhone}, []map[string]interface{}{profile, emailClaims, phoneClaims}},
+		{"permissions only", []string{models.PermissionUsersRead}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := map[string]interface{}{"sub": "user-1"}
+			for _, claims := range test.claims {
+				for name, value := range claims {
+					want[name] = value
+				}
+			}
+
+			if claims := OpenIDClaims(user, test.scopes); !reflect.DeepEqual(claims, want) {
+				t.Fatalf("got %v, want %v", claims, want)
+			}
+		})
+	}
+
+	// Missing names and phone numbers aren't claimed as empty.
+	claims := OpenIDClaims(models.User{UserID: "user-2", FirstName: &firstName}, []string{models.ScopeProfile, models.ScopePhone})
+	if claims["name"] != firstName || claims["family_name"] != nil || claims["phone_number"] != nil {
+		t.Fatalf("got %v", claims)
+	}
+}
+
+// Tests that ID tokens are only issued when the `openid` scope is asked for explicitly.
+func TestRequestsIDToken(t *testing.T) {
+	if !RequestsIDToken([]string{models.ScopeOpenID, models.ScopeProfile}) {
+		t.Error("the openid scope didn't ask for an ID token")
+	}
+	if RequestsIDToken([]string{ScopeAll}) || RequestsIDToken([]string{models.ScopeProfile, models.ScopeEmail}) {
+		t.Error("an ID token was asked for without the openid scope")
+	}
+}
@@ -0,0 +1,88 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Represents the `organization` collection in the MongoDB database.
+var organizationCollection *mongo.Collection = openOrganizationCollection()
+
+// Opens the `organization` collection, making organization IDs and slugs unique.
+func openOrganizationCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "organization")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "orgid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "slug", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Finds the organization identified by `orgID`.
+func FindOrganization(ctx context.Context, orgID string) (organization models.Organization, err error) {
+	err = organizationCollection.FindOne(ctx, bson.M{"orgid": orgID}).Decode(&organization)
+	return organization, err
+}
+
+// Returns the tenant that the emails of the accounts created in `organization` are unique among, empty when they are
+// unique across the service.
+func EmailTenant(organization models.Organization) string {
+	if organization.EmailUniqueness == models.EmailUniquenessTenant {
+		return organization.OrgID
+	}
+
+	return ""
+}
+
+// Returns the filter matching the accounts registered with `email` among the accounts of `tenantID`, or among the
+// accounts whose email is unique across the service when `tenantID` is empty.
+func EmailFilter(email, tenantID string) bson.M {
+	if tenantID == "" {
+		// Accounts created before organizations existed have no `tenantid` at all.
+		return bson.M{"email": email, "tenantid": bson.M{"$in": []interface{}{"", nil}}}
+	}
+
+	return bson.M{"email": email, "tenantid": tenantID}
+}
+
+// Returns the filter finding the account a user signing in to the organization `orgID` with `email` refers to.
+// An empty `orgID` looks among the accounts whose email is unique across the service.
+func AccountFilter(ctx context.Context, email, orgID string) (bson.M, error) {
+	if orgID == "" {
+		return EmailFilter(email, ""), nil
+	}
+
+	organization, err := FindOrganization(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return EmailFilter(email, EmailTenant(organization)), nil
+}
+
+// Returns the membership of `user` in the organization `orgID`, if the user belongs to it.
+func FindMembership(user models.User, orgID string) (models.OrgMembership, bool) {
+	for _, membership := range user.Memberships {
+		if membership.OrgID == orgID {
+			return membership, true
+		}
+	}
+
+	return models.OrgMembership{}, false
+}
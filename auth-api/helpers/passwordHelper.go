@@ -0,0 +1,228 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashes and verifies passwords with a single algorithm. Hashes are self-describing PHC-style strings
+// (e.g. `$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>`) that record the algorithm and its parameters.
+type PasswordHasher interface {
+	// Returns the encoded hash of `password`.
+	Hash(password string) (string, error)
+	// Returns whether `password` matches the encoded hash `encoded`.
+	Verify(password, encoded string) (bool, error)
+	// Returns whether `encoded` was produced by this hasher.
+	Recognizes(encoded string) bool
+	// Returns whether `encoded` was produced with weaker parameters than the hasher currently uses.
+	IsWeaker(encoded string) bool
+	// Returns the longest password the hasher accepts, in bytes, or 0 if it accepts any length.
+	MaxPasswordBytes() int
+}
+
+// Hashes passwords with argon2id, as recommended by RFC 9106.
+type Argon2idHasher struct {
+	// Memory used per hash, in KiB.
+	Memory uint32
+	// Number of passes over the memory.
+	Iterations uint32
+	// Number of threads used.
+	Parallelism uint8
+	// Length of the random salt, in bytes.
+	SaltLength uint32
+	// Length of the derived key, in bytes.
+	KeyLength uint32
+}
+
+// Hashes passwords with bcrypt, which only considers the first 72 bytes of a password.
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hasher used for every new password, selected with the `PASSWORD_HASHER` environment variable (`argon2id`,
+// the default, or `bcrypt`). Its cost is configured with `ARGON2_MEMORY`, `ARGON2_ITERATIONS`, `ARGON2_PARALLELISM`
+// and `BCRYPT_COST`.
+var CurrentPasswordHasher PasswordHasher = newPasswordHasher(os.Getenv("PASSWORD_HASHER"))
+
+// Every hasher passwords may have been stored with, including the ones that are no longer current.
+var passwordHashers []PasswordHasher = []PasswordHasher{
+	CurrentPasswordHasher,
+	&Argon2idHasher{},
+	&BcryptHasher{},
+}
+
+// Returns the hasher named `name`, configured from the environment.
+func newPasswordHasher(name string) PasswordHasher {
+	if name == "bcrypt" {
+		return &BcryptHasher{Cost: envIntInRange("BCRYPT_COST", 12, bcrypt.MinCost, bcrypt.MaxCost)}
+	}
+
+	return &Argon2idHasher{
+		Memory:      uint32(envIntInRange("ARGON2_MEMORY", 64*1024, 8, math.MaxInt32)),
+		Iterations:  uint32(envIntInRange("ARGON2_ITERATIONS", 3, 1, math.MaxInt32)),
+		Parallelism: uint8(envIntInRange("ARGON2_PARALLELISM", 2, 1, math.MaxUint8)),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Hashes `password` with the current hasher.
+func HashPassword(password string) (string, error) {
+	return CurrentPasswordHasher.Hash(password)
+}
+
+// Returns whether `password` matches the encoded hash `encoded`, whichever hasher produced it.
+func CheckPassword(password, encoded string) bool {
+	for _, hasher := range passwordHashers {
+		if hasher.Recognizes(encoded) {
+			ok, err := hasher.Verify(password, encoded)
+			return err == nil && ok
+		}
+	}
+
+	return false
+}
+
+// Returns whether `encoded` should be replaced by a new hash, because it was produced by another algorithm
+// than the current one or with weaker parameters.
+func PasswordNeedsRehash(encoded string) bool {
+	return !CurrentPasswordHasher.Recognizes(encoded) || CurrentPasswordHasher.IsWeaker(encoded)
+}
+
+// Returns the encoded argon2id hash of `password`.
+func (hasher *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, hasher.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, hasher.Iterations, hasher.Memory, hasher.Parallelism, hasher.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, hasher.Memory, hasher.Iterations, hasher.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Returns whether `password` matches the encoded argon2id hash `encoded`, using the parameters recorded in it.
+func (hasher *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2idHash(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+// Returns whether `encoded` is an argon2id hash.
+func (hasher *Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+// Returns whether `encoded` uses less memory, fewer iterations or a shorter salt or key than the hasher.
+func (hasher *Argon2idHasher) IsWeaker(encoded string) bool {
+	params, salt, key, err := decodeArgon2idHash(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory < hasher.Memory ||
+		params.Iterations < hasher.Iterations ||
+		uint32(len(salt)) < hasher.SaltLength ||
+		uint32(len(key)) < hasher.KeyLength
+}
+
+// Parses an encoded argon2id hash into its parameters, salt and key.
+func decodeArgon2idHash(encoded string) (params Argon2idHasher, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errors.New("the password hash is invalid")
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("the password hash version is not supported")
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, errors.New("the password hash is invalid")
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, errors.New("the password hash is invalid")
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("the password hash is invalid")
+	}
+
+	return params, salt, key, nil
+}
+
+// Returns the bcrypt hash of `password`, refusing passwords bcrypt would silently truncate.
+func (hasher *BcryptHasher) Hash(password string) (string, error) {
+	if len(password) > hasher.MaxPasswordBytes() {
+		return "", errors.New("bcrypt passwords can't be longer than 72 bytes")
+	}
+
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
+	return string(bytes), err
+}
+
+// Returns whether `password` matches the bcrypt hash `encoded`.
+func (hasher *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Returns 0, as argon2id hashes passwords of any length.
+func (hasher *Argon2idHasher) MaxPasswordBytes() int {
+	return 0
+}
+
+// Returns 72, as bcrypt would ignore the bytes beyond it.
+func (hasher *BcryptHasher) MaxPasswordBytes() int {
+	return 72
+}
+
+// Returns whether `encoded` is a bcrypt hash.
+func (hasher *BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// Returns whether `encoded` has a lower cost than the hasher.
+func (hasher *BcryptHasher) IsWeaker(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < hasher.Cost
+}
+
+// Returns the value of the environment variable `key` as an integer, or `fallback` if it isn't set. A value outside
+// of `min` and `max` stops the service, rather than being silently wrapped into the hasher's parameters.
+func envIntInRange(key string, fallback, min, max int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		log.Fatalf("%s must be an integer between %d and %d.", key, min, max)
+	}
+
+	return value
+}
@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"time"
+)
+
+// Number of previous passwords a new password may not match, taken from the `PASSWORD_HISTORY_SIZE` environment variable.
+var PASSWORD_HISTORY_SIZE int = envIntOrDefault("PASSWORD_HISTORY_SIZE", 5)
+
+// Number of days after which a password must be changed at the next login, taken from the `PASSWORD_MAX_AGE_DAYS`
+// environment variable. Passwords never expire when it isn't set.
+var PASSWORD_MAX_AGE_DAYS int = envIntOrDefault("PASSWORD_MAX_AGE_DAYS", 0)
+
+// Returns whether `password` matches `currentHash` or any of the `history` hashes.
+func PasswordReused(password, currentHash string, history []string) bool {
+	if CheckPassword(password, currentHash) {
+		return true
+	}
+
+	for _, hash := range history {
+		if CheckPassword(password, hash) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns whether a password last changed at `changedAt` has outlived `PASSWORD_MAX_AGE_DAYS`.
+func PasswordExpired(changedAt time.Time) bool {
+	if PASSWORD_MAX_AGE_DAYS == 0 || changedAt.IsZero() {
+		return false
+	}
+
+	return time.Since(changedAt) > time.Duration(PASSWORD_MAX_AGE_DAYS)*24*time.Hour
+}
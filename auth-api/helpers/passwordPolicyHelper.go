@@ -0,0 +1,326 @@
+package helpers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules every new password has to follow.
+type PasswordPolicy struct {
+	// Minimum and maximum length, in characters.
+	MinLength int
+	MaxLength int
+	// Maximum length in bytes, which the password hasher may limit, or 0 for no limit.
+	MaxBytes int
+	// Minimum number of character classes (lowercase, uppercase, digits, symbols) used.
+	MinCharacterClasses int
+	// Minimum strength score, from 0 (trivially guessable) to 4 (very hard to guess).
+	MinStrength int
+	// Whether passwords containing the user's name or email are refused.
+	DisallowPersonalInfo bool
+	// Minimum number of times a password must have appeared in the breached password corpus to be refused.
+	MinBreachCount int
+}
+
+// Returned when a password doesn't follow the policy, listing every rule it breaks.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+// Returns the violations as a single message.
+func (err *PasswordPolicyError) Error() string {
+	return "the password " + strings.Join(err.Violations, ", ") + "."
+}
+
+// Policy applied to every new password, configured with the `PASSWORD_MIN_LENGTH`, `PASSWORD_MAX_LENGTH`,
+// `PASSWORD_MIN_CLASSES`, `PASSWORD_MIN_STRENGTH` and `BREACHED_PASSWORD_MIN_COUNT` environment variables.
+var CurrentPasswordPolicy PasswordPolicy = PasswordPolicy{
+	MinLength:            envIntOrDefault("PASSWORD_MIN_LENGTH", 8),
+	MaxLength:            envIntOrDefault("PASSWORD_MAX_LENGTH", 128),
+	MaxBytes:             CurrentPasswordHasher.MaxPasswordBytes(),
+	MinCharacterClasses:  envIntOrDefault("PASSWORD_MIN_CLASSES", 1),
+	MinStrength:          envIntOrDefault("PASSWORD_MIN_STRENGTH", 2),
+	DisallowPersonalInfo: true,
+	MinBreachCount:       envIntOrDefault("BREACHED_PASSWORD_MIN_COUNT", 1),
+}
+
+// Location of the breached password corpus, taken from the `BREACHED_PASSWORDS_PATH` environment variable.
+// It is either a directory of k-anonymity range files, named after the first 5 hex characters of the SHA-1 hash
+// (optionally with a `.txt` extension) and holding `SUFFIX:COUNT` lines, or a single file of `HASH:COUNT` lines
+// that is loaded into memory. Without it, the breach check is skipped.
+var BREACHED_PASSWORDS_PATH string = os.Getenv("BREACHED_PASSWORDS_PATH")
+
+// Full SHA-1 hashes and counts of the corpus, when it is a single file.
+var breachedPasswords map[string]int = loadBreachedPasswords(BREACHED_PASSWORDS_PATH)
+
+// Common passwords and words, matched ignoring case and leetspeak, that make a password much easier to guess.
+var commonPasswordWords = []string{
+	"password", "passwort", "qwerty", "azerty", "letmein", "welcome", "admin", "login", "master", "secret",
+	"dragon", "monkey", "football", "baseball", "soccer", "hockey", "shadow", "sunshine", "princess", "iloveyou",
+	"trustno1", "superman", "batman", "starwars", "whatever", "freedom", "computer", "internet", "default",
+	"changeme", "abc123", "123456", "654321", "111111", "000000", "123123", "qazwsx", "asdfgh", "zxcvbn",
+}
+
+// Rows of a QWERTY keyboard, used to detect keyboard walks.
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// Checks `password` against the policy, refusing passwords containing any of the `personalInfo` values.
+func (policy PasswordPolicy) Validate(password string, personalInfo ...string) error {
+	violations := []string{}
+	length := utf8.RuneCountInString(password)
+
+	if length < policy.MinLength {
+		violations = append(violations, "must be at least "+strconv.Itoa(policy.MinLength)+" characters long")
+	}
+	if length > policy.MaxLength {
+		violations = append(violations, "must be at most "+strconv.Itoa(policy.MaxLength)+" characters long")
+	} else if policy.MaxBytes > 0 && len(password) > policy.MaxBytes {
+		violations = append(violations, "must be at most "+strconv.Itoa(policy.MaxBytes)+" bytes long, counting accented and other non-ASCII characters as several bytes")
+	}
+	if characterClasses(password) < policy.MinCharacterClasses {
+		violations = append(violations, "must use at least "+strconv.Itoa(policy.MinCharacterClasses)+" of lowercase letters, uppercase letters, digits and symbols")
+	}
+
+	if policy.DisallowPersonalInfo {
+		lowered := strings.ToLower(password)
+		for _, info := range personalInfo {
+			info = strings.ToLower(strings.TrimSpace(info))
+			if utf8.RuneCountInString(info) >= 3 && strings.Contains(lowered, info) {
+				violations = append(violations, "must not contain your name or email")
+				break
+			}
+		}
+	}
+
+	if PasswordStrength(password) < policy.MinStrength {
+		violations = append(violations, "is too easy to guess")
+	}
+	if BreachCount(password) >= policy.MinBreachCount {
+		violations = append(violations, "has appeared in a data breach")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+// Returns the number of character classes (lowercase, uppercase, digits, symbols) used in `password`.
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, char := range password {
+		switch {
+		case unicode.IsLower(char):
+			lower = 1
+		case unicode.IsUpper(char):
+			upper = 1
+		case unicode.IsDigit(char):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
+
+// Scores how hard `password` is to guess from 0 to 4, in the style of zxcvbn: the number of guesses is estimated
+// from the character pool and length, discounting repeated characters, sequences, keyboard walks and common words,
+// and the score is derived from the same thresholds (10^3, 10^6, 10^8 and 10^10 guesses).
+func PasswordStrength(password string) int {
+	bits := passwordGuessBits(password)
+
+	switch {
+	case bits < 3*math.Log2(10):
+		return 0
+	case bits < 6*math.Log2(10):
+		return 1
+	case bits < 8*math.Log2(10):
+		return 2
+	case bits < 10*math.Log2(10):
+		return 3
+	}
+
+	return 4
+}
+
+// Estimates log2 of the number of guesses needed to find `password`.
+func passwordGuessBits(password string) float64 {
+	lowered := strings.ToLower(password)
+	runes := []rune(lowered)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	// Size of the character pool, from the classes used.
+	pool := 0
+	for _, char := range password {
+		switch {
+		case char >= 'a' && char <= 'z':
+			pool |= 1
+		case char >= 'A' && char <= 'Z':
+			pool |= 2
+		case char >= '0' && char <= '9':
+			pool |= 4
+		case char < 128:
+			pool |= 8
+		default:
+			pool |= 16
+		}
+	}
+	poolSize := 0
+	for class, size := range map[int]int{1: 26, 2: 26, 4: 10, 8: 33, 16: 100} {
+		if pool&class != 0 {
+			poolSize += size
+		}
+	}
+	bitsPerChar := math.Log2(float64(poolSize))
+
+	// Characters covered by a common word, as is or written in leetspeak, only cost the guesses needed to pick
+	// the word from a dictionary. Leetspeak substitutions are single ASCII characters, so offsets line up.
+	covered := make([]bool, len(runes))
+	bits := 0.0
+	for _, candidate := range []string{lowered, undoLeetspeak(lowered)} {
+		for _, word := range commonPasswordWords {
+			for offset := 0; offset < len(candidate); {
+				index := strings.Index(candidate[offset:], word)
+				if index < 0 {
+					break
+				}
+				start := utf8.RuneCountInString(candidate[:offset+index])
+				fresh := false
+				for i := start; i < start+len(word); i++ {
+					if !covered[i] {
+						fresh = true
+					}
+					covered[i] = true
+				}
+				if fresh {
+					bits += math.Log2(float64(len(commonPasswordWords))) + 1
+				}
+				offset += index + 1
+			}
+		}
+	}
+
+	// Every other character costs a full pick from the pool, unless it repeats or continues a sequence or keyboard walk.
+	for i, char := range runes {
+		if covered[i] {
+			continue
+		}
+		if i > 0 && (char == runes[i-1] || char == runes[i-1]+1 || char == runes[i-1]-1 || keyboardAdjacent(runes[i-1], char)) {
+			bits += 1
+			continue
+		}
+		bits += bitsPerChar
+	}
+
+	return bits
+}
+
+// Returns whether `b` follows `a` on a row of the keyboard, in either direction.
+func keyboardAdjacent(a, b rune) bool {
+	for _, row := range keyboardRows {
+		index := strings.IndexRune(row, a)
+		if index < 0 {
+			continue
+		}
+		if (index+1 < len(row) && rune(row[index+1]) == b) || (index > 0 && rune(row[index-1]) == b) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Replaces common leetspeak substitutions by the letters they stand for.
+func undoLeetspeak(password string) string {
+	return strings.NewReplacer("@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t").Replace(password)
+}
+
+// Returns how many times `password` appears in the breached password corpus, or 0 if no corpus is configured.
+func BreachCount(password string) int {
+	if BREACHED_PASSWORDS_PATH == "" {
+		return 0
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// Single file corpora are looked up in memory.
+	if breachedPasswords != nil {
+		return breachedPasswords[hash]
+	}
+
+	// Range file corpora are looked up by reading the file of the hash prefix.
+	prefix, suffix := hash[:5], hash[5:]
+	file, err := os.Open(filepath.Join(BREACHED_PASSWORDS_PATH, prefix))
+	if err != nil {
+		if file, err = os.Open(filepath.Join(BREACHED_PASSWORDS_PATH, prefix+".txt")); err != nil {
+			return 0
+		}
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entrySuffix, count := parseBreachLine(scanner.Text())
+		if entrySuffix == suffix {
+			return count
+		}
+	}
+
+	return 0
+}
+
+// Loads a single file corpus into memory, returning nil if `path` is empty or a directory.
+func loadBreachedPasswords(path string) map[string]int {
+	if path == "" {
+		return nil
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	defer file.Close()
+
+	passwords := map[string]int{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, count := parseBreachLine(scanner.Text())
+		if hash != "" {
+			passwords[hash] = count
+		}
+	}
+
+	return passwords
+}
+
+// Parses a `HASH:COUNT` line of the corpus, a missing count counts as 1.
+func parseBreachLine(line string) (hash string, count int) {
+	parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+	hash = strings.ToUpper(parts[0])
+	count = 1
+	if len(parts) == 2 {
+		if parsed, err := strconv.Atoi(parts[1]); err == nil {
+			count = parsed
+		}
+	}
+
+	return hash, count
+}
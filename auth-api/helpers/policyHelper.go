@@ -0,0 +1,345 @@
+package helpers
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v2"
+)
+
+// Policy used when no `POLICY_PATH` is set: tokens are limited to their scopes, users may read themselves, and
+// anything else needs a permission granted by the user's roles, or by its roles in the organization of the token for
+// resources of that organization.
+//
+//go:embed defaultPolicy.json
+var defaultPolicy []byte
+
+// Location of a JSON or YAML policy file replacing the default policy, taken from the `POLICY_PATH` environment variable.
+var POLICY_PATH string = os.Getenv("POLICY_PATH")
+
+// Policy every authorization decision is made with, loaded once at startup.
+var CurrentPolicy models.Policy = loadPolicy(POLICY_PATH)
+
+// Loads the policy at `path`, or the default policy if `path` is empty. An invalid policy stops the service, as
+// running with other rules than intended isn't safe.
+func loadPolicy(path string) models.Policy {
+	data, format := defaultPolicy, ".json"
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Fatal(err)
+		}
+		format = strings.ToLower(filepath.Ext(path))
+	}
+
+	policy, err := ParsePolicy(data, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return policy
+}
+
+// Parses and validates a policy written in JSON, or in YAML when `format` is `.yaml` or `.yml`.
+func ParsePolicy(data []byte, format string) (policy models.Policy, err error) {
+	if format == ".yaml" || format == ".yml" {
+		err = yaml.Unmarshal(data, &policy)
+	} else {
+		err = json.Unmarshal(data, &policy)
+	}
+	if err != nil {
+		return policy, err
+	}
+
+	return policy, ValidatePolicy(policy)
+}
+
+// Returns an error describing the first problem found in `policy`.
+func ValidatePolicy(policy models.Policy) error {
+	ids := map[string]bool{}
+	for index, rule := range policy.Rules {
+		if rule.ID == "" {
+			return fmt.Errorf("the policy rule %d has no id", index)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("the policy rule id %s is used more than once", rule.ID)
+		}
+		ids[rule.ID] = true
+
+		if rule.Effect != models.PolicyEffectAllow && rule.Effect != models.PolicyEffectDeny {
+			return fmt.Errorf("the policy rule %s has an invalid effect", rule.ID)
+		}
+		if len(rule.Actions) == 0 {
+			return fmt.Errorf("the policy rule %s has no actions", rule.ID)
+		}
+		for _, action := range rule.Actions {
+			if !ValidPermission(action) {
+				return fmt.Errorf("the policy rule %s has an invalid action %s", rule.ID, action)
+			}
+		}
+		for _, condition := range rule.Conditions {
+			switch condition.Operator {
+			case models.PolicyOperatorEquals, models.PolicyOperatorNotEquals, models.PolicyOperatorIn, models.PolicyOperatorContains,
+				models.PolicyOperatorGrants, models.PolicyOperatorNotGrants, models.PolicyOperatorExists:
+			default:
+				return fmt.Errorf("the policy rule %s has an invalid operator %s", rule.ID, condition.Operator)
+			}
+			if condition.Attribute == "" {
+				return fmt.Errorf("the policy rule %s has a condition without an attribute", rule.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns a non-nil error unless the current policy allows the authenticated user of the HTTP request to perform
+// `action` on `resource`.
+func Authorize(c *gin.Context, action string, resource models.PolicyResource) error {
+	subject, err := requestSubject(c)
+	if err != nil {
+		return errors.New("Unauthorized to access this resource")
+	}
+
+	decision := EvaluatePolicy(CurrentPolicy, PolicyAttributes(subject, action, resource, c), false)
+	if !decision.Allowed {
+		return errors.New("Unauthorized to access this resource")
+	}
+
+	return nil
+}
+
+// Resolves the subject of the HTTP request from the claims set by the `Authenticate()` middleware and the user's roles.
+// The permissions computed when the token was issued are used when it carries them, so changes to the user's roles
+// and groups apply to the tokens issued afterwards.
+func requestSubject(c *gin.Context) (models.PolicySubject, error) {
+	if cached, ok := c.Get("policy_subject"); ok {
+		return cached.(models.PolicySubject), nil
+	}
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	subject, err := LoadPolicySubject(ctx, c.GetString("user_id"), c.GetString("scope"), c.GetString("tenant"))
+	if err != nil {
+		return subject, err
+	}
+	if permissions, ok := c.Get("permissions"); ok {
+		subject.Permissions = permissions.([]string)
+	}
+	c.Set("policy_subject", subject)
+
+	return subject, nil
+}
+
+// Resolves the subject for the user `userID` holding a token limited to `scope` within the organization `tenant`.
+func LoadPolicySubject(ctx context.Context, userID, scope, tenant string) (models.PolicySubject, error) {
+	subject := models.PolicySubject{UserID: userID, Tenant: tenant}
+
+	var user models.User
+	err := userCollection.FindOne(ctx, bson.M{"userid": userID}, options.FindOne().SetProjection(bson.M{"roles": 1, "groups": 1, "usertype": 1, "memberships": 1})).Decode(&user)
+	if err != nil {
+		return subject, err
+	}
+	if user.UserType != nil {
+		subject.UserType = *user.UserType
+	}
+	if subject.Roles, subject.Permissions, err = EffectiveAccess(ctx, user); err != nil {
+		return subject, err
+	}
+	if subject.Scopes, err = ParseScope(scope); err != nil {
+		return subject, err
+	}
+
+	// The roles held in the organization only count while the user is still a member of it.
+	if membership, ok := FindMembership(user, tenant); ok && tenant != "" {
+		subject.TenantRoles = membership.Roles
+		if subject.TenantPermissions, err = RolePermissions(ctx, membership.Roles); err != nil {
+			return subject, err
+		}
+	}
+
+	return subject, nil
+}
+
+// Returns the attributes conditions can refer to, for `subject` performing `action` on `resource` in the request `c`.
+func PolicyAttributes(subject models.PolicySubject, action string, resource models.PolicyResource, c *gin.Context) map[string]interface{} {
+	attributes := map[string]interface{}{
+		"action":                     action,
+		"subject.user_id":            subject.UserID,
+		"subject.user_type":          subject.UserType,
+		"subject.roles":              subject.Roles,
+		"subject.permissions":        subject.Permissions,
+		"subject.scopes":             subject.Scopes,
+		"subject.tenant":             subject.Tenant,
+		"subject.tenant_roles":       subject.TenantRoles,
+		"subject.tenant_permissions": subject.TenantPermissions,
+		"resource.type":              resource.Type,
+		"resource.id":                resource.ID,
+		"resource.owner_id":          resource.OwnerID,
+		"resource.tenant":            resource.Tenant,
+	}
+	for key, value := range resource.Attributes {
+		attributes["resource."+key] = value
+	}
+	if c != nil {
+		attributes["context.ip"] = c.ClientIP()
+		attributes["context.method"] = c.Request.Method
+		attributes["context.path"] = c.FullPath()
+	}
+
+	return attributes
+}
+
+// Evaluates `policy` against a request described by `attributes`. When `explain` is set, the evaluation of every
+// rule is kept on the decision.
+func EvaluatePolicy(policy models.Policy, attributes map[string]interface{}, explain bool) models.PolicyDecision {
+	action, _ := attributes["action"].(string)
+	decision := models.PolicyDecision{Action: action}
+	var deniedBy, allowedBy string
+
+	for _, rule := range policy.Rules {
+		matched, reason := evaluateRule(rule, attributes)
+		if explain {
+			decision.Evaluations = append(decision.Evaluations, models.PolicyRuleEvaluation{RuleID: rule.ID, Effect: rule.Effect, Matched: matched, Reason: reason})
+		}
+
+		switch {
+		case !matched:
+		case rule.Effect == models.PolicyEffectDeny && deniedBy == "":
+			deniedBy = rule.ID
+		case rule.Effect == models.PolicyEffectAllow && allowedBy == "":
+			allowedBy = rule.ID
+		}
+
+		// Nothing can override a deny rule, so the remaining rules only need evaluating to explain the decision.
+		if deniedBy != "" && !explain {
+			break
+		}
+	}
+
+	switch {
+	case deniedBy != "":
+		decision.RuleID = deniedBy
+		decision.Reason = "denied by rule " + deniedBy
+	case allowedBy != "":
+		decision.Allowed = true
+		decision.RuleID = allowedBy
+		decision.Reason = "allowed by rule " + allowedBy
+	default:
+		decision.Reason = "no rule allows the request"
+	}
+
+	return decision
+}
+
+// Returns whether `rule` matches the request, or why it doesn't.
+func evaluateRule(rule models.PolicyRule, attributes map[string]interface{}) (bool, string) {
+	action, _ := attributes["action"].(string)
+	if !HasPermission(rule.Actions, action) {
+		return false, "the action isn't listed"
+	}
+
+	if len(rule.Resources) > 0 {
+		resourceType, _ := attributes["resource.type"].(string)
+		listed := false
+		for _, candidate := range rule.Resources {
+			listed = listed || candidate == resourceType
+		}
+		if !listed {
+			return false, "the resource type isn't listed"
+		}
+	}
+
+	for _, condition := range rule.Conditions {
+		if !evaluateCondition(condition, attributes) {
+			return false, fmt.Sprintf("the condition %s %s doesn't hold", condition.Attribute, condition.Operator)
+		}
+	}
+
+	return true, ""
+}
+
+// Returns whether `condition` holds for the request.
+func evaluateCondition(condition models.PolicyCondition, attributes map[string]interface{}) bool {
+	attribute := attributes[condition.Attribute]
+	value := condition.Value
+	if condition.ValueAttribute != "" {
+		value = attributes[condition.ValueAttribute]
+	}
+
+	switch condition.Operator {
+	case models.PolicyOperatorEquals:
+		return policyString(attribute) == policyString(value)
+	case models.PolicyOperatorNotEquals:
+		return policyString(attribute) != policyString(value)
+	case models.PolicyOperatorIn:
+		return containsString(policyStrings(value), policyString(attribute))
+	case models.PolicyOperatorContains:
+		return containsString(policyStrings(attribute), policyString(value))
+	case models.PolicyOperatorGrants:
+		return HasPermission(policyStrings(attribute), policyString(value))
+	case models.PolicyOperatorNotGrants:
+		return !HasPermission(policyStrings(attribute), policyString(value))
+	case models.PolicyOperatorExists:
+		return len(policyStrings(attribute)) > 0 && policyStrings(attribute)[0] != ""
+	}
+
+	return false
+}
+
+// Returns `value` as a string, or an empty string if it isn't a scalar.
+func policyString(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case []string, []interface{}:
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+// Returns `value` as a list of strings, a scalar being a list of one.
+func policyStrings(value interface{}) []string {
+	switch typed := value.(type) {
+	case nil:
+		return nil
+	case []string:
+		return typed
+	case []interface{}:
+		values := make([]string, 0, len(typed))
+		for _, item := range typed {
+			values = append(values, policyString(item))
+		}
+		return values
+	}
+
+	return []string{policyString(value)}
+}
+
+// Returns whether `values` contains `value`.
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Tests the decisions of the default policy, and the rule the explain output says decided them.
+func TestEvaluateDefaultPolicy(t *testing.T) {
+	self := models.PolicySubject{UserID: "user-1", UserType: models.RoleUser, Scopes: []string{ScopeAll}, Permissions: []string{}}
+	admin := models.PolicySubject{UserID: "admin-1", UserType: models.RoleAdmin, Scopes: []string{ScopeAll}, Permissions: []string{models.PermissionAll}}
+	narrowedAdmin := admin
+	narrowedAdmin.Scopes = []string{models.PermissionUsersRead}
+	tenantAdmin := models.PolicySubject{
+		UserID:            "user-2",
+		Scopes:            []string{ScopeAll},
+		Permissions:       []string{},
+		Tenant:            "org-1",
+		TenantRoles:       []string{"ORG_ADMIN"},
+		TenantPermissions: []string{models.PermissionOrgsMembers},
+	}
+	ownUser := models.PolicyResource{Type: "user", ID: "user-1", OwnerID: "user-1"}
+	otherUser := models.PolicyResource{Type: "user", ID: "user-3", OwnerID: "user-3"}
+
+	tests := []struct {
+		name     string
+		subject  models.PolicySubject
+		action   string
+		resource models.PolicyResource
+		allowed  bool
+		ruleID   string
+	}{
+		{"self read", self, models.PermissionUsersRead, ownUser, true, "user-self"},
+		{"self write", self, models.PermissionUsersWrite, ownUser, false, ""},
+		{"other user read", self, models.PermissionUsersRead, otherUser, false, ""},
+		{"admin read", admin, models.PermissionUsersRead, otherUser, true, "role-permission"},
+		{"admin write", admin, models.PermissionUsersWrite, otherUser, true, "role-permission"},
+		{"admin any action", admin, models.PermissionAuditRead, models.PolicyResource{}, true, "role-permission"},
+		{"tenant role in its organization", tenantAdmin, models.PermissionOrgsMembers, models.PolicyResource{Type: "organization", ID: "org-1", Tenant: "org-1"}, true, "tenant-role-permission"},
+		{"tenant role in another organization", tenantAdmin, models.PermissionOrgsMembers, models.PolicyResource{Type: "organization", ID: "org-2", Tenant: "org-2"}, false, ""},
+		{"tenant role outside any organization", tenantAdmin, models.PermissionOrgsMembers, models.PolicyResource{}, false, ""},
+		{"scope denies an allowed action", narrowedAdmin, models.PermissionUsersWrite, otherUser, false, "token-scope"},
+		{"scope keeps a scoped action", narrowedAdmin, models.PermissionUsersRead, otherUser, true, "role-permission"},
+		{"scope denies reading self", models.PolicySubject{UserID: "user-1", Scopes: []string{models.ScopeAccount}}, models.PermissionUsersRead, ownUser, false, "token-scope"},
+		{"no matching rule", models.PolicySubject{UserID: "user-1", Scopes: []string{ScopeAll}}, models.PermissionRolesWrite, models.PolicyResource{Type: "role"}, false, ""},
+	}
+
+	policy := loadPolicy("")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := EvaluatePolicy(policy, PolicyAttributes(test.subject, test.action, test.resource, nil), true)
+			if decision.Allowed != test.allowed || decision.RuleID != test.ruleID {
+				t.Fatalf("got allowed %v by %q (%s), want allowed %v by %q", decision.Allowed, decision.RuleID, decision.Reason, test.allowed, test.ruleID)
+			}
+
+			// Every rule is explained, and the deciding rule is reported as matched.
+			if len(decision.Evaluations) != len(policy.Rules) {
+				t.Fatalf("got %d evaluations, want %d", len(decision.Evaluations), len(policy.Rules))
+			}
+			for _, evaluation := range decision.Evaluations {
+				if evaluation.RuleID == test.ruleID && !evaluation.Matched {
+					t.Errorf("the deciding rule %s isn't reported as matched: %s", evaluation.RuleID, evaluation.Reason)
+				}
+				if !evaluation.Matched && evaluation.Reason == "" {
+					t.Errorf("the rule %s didn't match without a reason", evaluation.RuleID)
+				}
+			}
+		})
+	}
+}
+
+// Tests conditions on the IP address of the request.
+func TestEvaluatePolicyContextIP(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+rules:
+  - id: office-network
+    effect: allow
+    actions: ["users:read"]
+    conditions:
+      - {attribute: context.ip, operator: in, value: ["10.0.0.1", "10.0.0.2"]}
+  - id: blocked-address
+    effect: deny
+    actions: ["*"]
+    conditions:
+      - {attribute: context.ip, operator: eq, value: "10.0.0.2"}
+`), ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remoteAddr string
+		allowed    bool
+		ruleID     string
+	}{
+		{"10.0.0.1:1234", true, "office-network"},
+		{"10.0.0.2:1234", false, "blocked-address"},
+		{"192.0.2.1:1234", false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.remoteAddr, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/users", nil)
+			c.Request.RemoteAddr = test.remoteAddr
+
+			decision := EvaluatePolicy(policy, PolicyAttributes(models.PolicySubject{}, models.PermissionUsersRead, models.PolicyResource{}, c), true)
+			if decision.Allowed != test.allowed || decision.RuleID != test.ruleID {
+				t.Fatalf("got allowed %v by %q, want allowed %v by %q", decision.Allowed, decision.RuleID, test.allowed, test.ruleID)
+			}
+		})
+	}
+
+	// Without a request, such as when explaining a decision, there is no address to match.
+	decision := EvaluatePolicy(policy, PolicyAttributes(models.PolicySubject{}, models.PermissionUsersRead, models.PolicyResource{}, nil), false)
+	if decision.Allowed {
+		t.Fatalf("got allowed by %q without a request", decision.RuleID)
+	}
+}
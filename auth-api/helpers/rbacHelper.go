@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Represents the `role` collection in the MongoDB database.
+var roleCollection *mongo.Collection = openRoleCollection()
+
+// Roles created at startup if they don't exist yet. `ADMIN` can do anything, `USER` can only act on itself.
+var BuiltinRoles = []models.Role{
+	{Name: models.RoleAdmin, Description: "Full access to every resource.", Permissions: []string{models.PermissionAll}},
+	{Name: models.RoleUser, Description: "Access to the user's own account only.", Permissions: []string{}},
+}
+
+// Format of a permission: `*`, `resource:*` or `resource:action`.
+var permissionPattern = regexp.MustCompile(`^(\*|[a-z_]+:(\*|[a-z_]+))$`)
+
+// Opens the `role` collection, makes role names unique and creates the built-in roles.
+func openRoleCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "role")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	// Only inserts the built-in roles that are missing, so changes made to them are kept.
+	now := time.Now().UTC()
+	for _, role := range BuiltinRoles {
+		_, err := collection.UpdateOne(ctx, bson.M{"name": role.Name}, bson.D{
+			{Key: "$setOnInsert", Value: bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "description", Value: role.Description},
+				{Key: "permissions", Value: role.Permissions},
+				{Key: "builtin", Value: true},
+				{Key: "createdat", Value: now},
+				{Key: "updatedat", Value: now},
+			}},
+		}, options.Update().SetUpsert(true))
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	return collection
+}
+
+// Returns whether `permission` is well formed.
+func ValidPermission(permission string) bool {
+	return permissionPattern.MatchString(permission)
+}
+
+// Returns whether the `granted` permissions include `permission`, directly or through a wildcard.
+func HasPermission(granted []string, permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+	for _, candidate := range granted {
+		if candidate == models.PermissionAll || candidate == permission || candidate == resource+":*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns every permission granted by the roles named `names`, roles that don't exist grant nothing.
+func RolePermissions(ctx context.Context, names []string) ([]string, error) {
+	cursor, err := roleCollection.Find(ctx, bson.M{"name": bson.M{"$in": names}})
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []models.Role
+	if err = cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+
+	permissions := []string{}
+	for _, role := range roles {
+		permissions = append(permissions, role.Permissions...)
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// Number of recovery codes generated for a user at a time.
+const RecoveryCodeCount = 10
+
+// Generates `RecoveryCodeCount` single-use recovery codes in the `xxxxx-xxxxx` format, alongside the hashes to store.
+func GenerateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		bytes := make([]byte, 10)
+		if _, err = rand.Read(bytes); err != nil {
+			return nil, nil, err
+		}
+
+		// 10 random bytes give 16 base32 characters, of which the first 10 (50 bits) are used.
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(bytes))[:10]
+		code := encoded[:5] + "-" + encoded[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, err
+}
+
+// Returns the hex encoded SHA-256 hash of `code`, ignoring case, spaces and dashes.
+// Recovery codes are random enough that a fast hash is sufficient, and it lets them be looked up directly.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(code)
+	normalized = strings.ReplaceAll(normalized, "-", "")
+	normalized = strings.ReplaceAll(normalized, " ", "")
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/kareem717/auth-api/models"
+)
+
+// Scope of an unrestricted token, which can do anything its user's roles allow.
+const ScopeAll = models.PermissionAll
+
+// Returns whether `scope` is one of the OpenID Connect scopes, which are the only scopes not written like permissions.
+func IsOpenIDScope(scope string) bool {
+	return scope == models.ScopeOpenID || scope == models.ScopeProfile || scope == models.ScopeEmail || scope == models.ScopePhone
+}
+
+// Parses a space separated `scope`, written like permissions or OpenID Connect scopes, into its scopes. An empty scope
+// is unrestricted.
+func ParseScope(scope string) ([]string, error) {
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		return []string{ScopeAll}, nil
+	}
+
+	for _, candidate := range scopes {
+		if !ValidPermission(candidate) && !IsOpenIDScope(candidate) {
+			return nil, errors.New("the scope " + candidate + " is invalid")
+		}
+	}
+
+	return scopes, nil
+}
+
+// Returns whether the `granted` scopes include every one of the `requested` scopes.
+func ScopeCovers(granted, requested []string) bool {
+	for _, scope := range requested {
+		if !HasPermission(granted, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the scope to issue for a request of `requested` by a holder of `granted`, refusing to widen it.
+func NarrowScope(granted, requested string) (string, error) {
+	grantedScopes, err := ParseScope(granted)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(requested) == "" {
+		return strings.Join(grantedScopes, " "), nil
+	}
+
+	requestedScopes, err := ParseScope(requested)
+	if err != nil {
+		return "", err
+	}
+	if !ScopeCovers(grantedScopes, requestedScopes) {
+		return "", errors.New("the requested scope is wider than the granted scope")
+	}
+
+	return strings.Join(requestedScopes, " "), nil
+}
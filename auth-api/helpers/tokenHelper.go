@@ -2,6 +2,10 @@ package helpers
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
@@ -21,6 +25,12 @@ type SignedDetails struct {
 	LastName  string
 	UID       string
 	UserType  string
+	// Space separated scopes the token is limited to, `*` for an unrestricted token.
+	Scope     string
+	// Organization the token acts within, empty for a token that isn't tied to any organization.
+	Tenant    string
+	// Permissions granted by the user's roles and groups when the token was issued.
+	Permissions []string
 	jwt.StandardClaims
 }
 
@@ -28,21 +38,91 @@ type SignedDetails struct {
 var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
 var SECRET_KEY string = os.Getenv("SECERET_KEY")
 
-// Generates a new JWT token and a new refresh token and returns them as strings.
-func GenerateAllTokens(email, firstName, lastName, userType, userID string) (signedToken, signedRefreshToken string, err error) {
+// Audience of the refresh tokens.
+const RefreshAudience = "refresh"
+
+// Audience of the challenge token handed out by `Login()` when a user still has to pass MFA.
+const MFAChallengeAudience = "mfa"
+
+// Number of minutes a user has to complete the MFA step of the login.
+const MFAChallengeMinutes = 5
+
+// Number of codes that can be submitted for a single MFA challenge, after which the user must log in again.
+const MFAChallengeMaxAttempts = 5
+
+// Audience of the challenge token handed out by `Login()` when a user's password has expired.
+const PasswordChangeAudience = "password-change"
+
+// Number of minutes a user has to choose a new password after logging in with an expired one.
+const PasswordChangeMinutes = 15
+
+// Audience of the tokens embedded in magic sign-in links.
+const MagicLinkAudience = "magic-link"
+
+// Number of minutes a magic sign-in link stays valid for.
+const MagicLinkMinutes = 15
+
+// Address the magic sign-in links point to, the token is appended as the `token` query parameter.
+var MAGIC_LINK_URL string = envOrDefault("MAGIC_LINK_URL", "http://localhost:8000/users/login/magic-link/callback")
+
+// Audience of the tokens embedded in password reset links.
+const PasswordResetAudience = "password-reset"
+
+// Number of minutes a password reset link stays valid for.
+const PasswordResetMinutes = 30
+
+// Address the password reset links point to, the token is appended as the `token` query parameter.
+var PASSWORD_RESET_URL string = envOrDefault("PASSWORD_RESET_URL", "http://localhost:3000/reset-password")
+
+// Audience of the access tokens issued to OAuth clients acting on their own behalf. They carry no user, so they are
+// meant for our other services, which verify them with the published OpenID Connect keys, and are never accepted as
+// access tokens by this one.
+const ClientAudience = "client"
+
+// Audience of the tokens embedded in organization invitation links.
+const InvitationAudience = "invitation"
+
+// Represents the claims that are encoded in a password reset link token, or in the challenge token of an expired password.
+type PasswordResetClaims struct {
+	// Hash of the password hash the token was issued for, so the token stops working once the password changes.
+	PasswordHash string
+	jwt.StandardClaims
+}
+
+// Represents the claims that are encoded in a magic sign-in link token.
+type MagicLinkClaims struct {
+	// Hash of the IP address the link was requested from, if the link is bound to it.
+	IPHash string
+	// Hash of the device cookie set when the link was requested, if the link is bound to it.
+	DeviceHash string
+	jwt.StandardClaims
+}
+
+// Generates a new JWT token granting `permissions` and a new refresh token, both limited to `scope` within the
+// organization `tenant`, and returns them as strings.
+func GenerateAllTokens(email, firstName, lastName, userType, userID, scope, tenant string, permissions []string) (signedToken, signedRefreshToken string, err error) {
 	claims := &SignedDetails {
 		Email: email,
 		FirstName: firstName,
 		LastName: lastName,
 		UID: userID,
 		UserType: userType,
+		Scope: scope,
+		Tenant: tenant,
+		Permissions: permissions,
 		StandardClaims: jwt.StandardClaims {
 			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(2)).Unix(),
 		},
 	}
 
+	// The refresh token carries an audience, so it can't be used as an access token, and the scope it can be
+	// and tenant it can be exchanged for at `/users/token/refresh`.
 	refreshClaims := &SignedDetails {
+		UID: userID,
+		Scope: scope,
+		Tenant: tenant,
 		StandardClaims: jwt.StandardClaims{
+			Audience: RefreshAudience,
 			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(4)).Unix(),
 		},
 	}
@@ -129,6 +209,277 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 		msg = fmt.Sprintf("token is expired.")
 	}
 
+	// Challenge tokens carry an audience and must never be accepted as access tokens.
+	if claims.Audience != "" {
+		msg = fmt.Sprintf("the token is invalid")
+	}
+
 	// Returns the claims and an empty string.
 	return claims, msg
 }
+
+// Validates the provided refresh token and returns its claims and any error message.
+func ValidateRefreshToken(signedToken string) (claims *SignedDetails, msg string) {
+	claims = &SignedDetails{}
+
+	// Parses the token using the secret key, refusing tokens signed with any other algorithm.
+	_, err := jwt.ParseWithClaims(
+		signedToken,
+		claims,
+		func(token *jwt.Token)(interface{}, error){
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method")
+			}
+			return []byte(SECRET_KEY), nil
+		},
+	)
+	if err != nil {
+		msg = err.Error()
+		return
+	}
+
+	// Only refresh tokens, which carry the refresh audience and a user, are accepted.
+	if !claims.VerifyAudience(RefreshAudience, true) || claims.UID == "" {
+		msg = "the token is invalid"
+	}
+
+	return claims, msg
+}
+
+// Generates the short-lived challenge token that must be exchanged, along with a valid MFA code, for the real tokens.
+// It is identified by `challengeID`, which counts the codes submitted for it.
+func GenerateMFAChallengeToken(userID, challengeID string) (signedToken string, err error) {
+	claims := &jwt.StandardClaims{
+		Id:        challengeID,
+		Subject:   userID,
+		Audience:  MFAChallengeAudience,
+		ExpiresAt: time.Now().Local().Add(time.Minute * time.Duration(MFAChallengeMinutes)).Unix(),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+}
+
+// Validates the provided MFA challenge token and returns the `user_id` and challenge ID it was issued for and any
+// error message.
+func ValidateMFAChallengeToken(signedToken string) (userID, challengeID string, msg string) {
+	claims := &jwt.StandardClaims{}
+
+	// Parses the token using the secret key, which also checks its expiry.
+	_, err := jwt.ParseWithClaims(
+		signedToken,
+		claims,
+		func(token *jwt.Token)(interface{}, error){
+			return []byte(SECRET_KEY), nil
+		},
+	)
+	if err != nil {
+		msg = err.Error()
+		return
+	}
+
+	// Makes sure the token was actually issued for the MFA step.
+	if !claims.VerifyAudience(MFAChallengeAudience, true) || claims.Subject == "" || claims.Id == "" {
+		msg = fmt.Sprintf("the token is invalid")
+		return
+	}
+
+	return claims.Subject, claims.Id, msg
+}
+
+// Opens the `mfa_challenge` collection, whose challenge IDs are unique, and deletes challenges that were never
+// completed once expired.
+func OpenMFAChallengeCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "mfa_challenge")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "challengeid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Generates `size` random bytes and returns them base64url encoded.
+func GenerateRandomToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// Returns the hex encoded SHA-256 hash of `value`, used to store or embed values that must not be readable.
+func HashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generates the token of a magic sign-in link for `userID`, identified by `tokenID` so it can only be redeemed once.
+func GenerateMagicLinkToken(userID, tokenID, ipHash, deviceHash string) (signedToken string, err error) {
+	claims := &MagicLinkClaims{
+		IPHash:     ipHash,
+		DeviceHash: deviceHash,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			Subject:   userID,
+			Audience:  MagicLinkAudience,
+			ExpiresAt: time.Now().Local().Add(time.Minute * time.Duration(MagicLinkMinutes)).Unix(),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+}
+
+// Validates the token of a magic sign-in link and returns its claims and any error message.
+func ValidateMagicLinkToken(signedToken string) (claims *MagicLinkClaims, msg string) {
+	claims = &MagicLinkClaims{}
+
+	// Parses the token using the secret key, which also checks its expiry.
+	_, err := jwt.ParseWithClaims(
+		signedToken,
+		claims,
+		func(token *jwt.Token)(interface{}, error){
+			return []byte(SECRET_KEY), nil
+		},
+	)
+	if err != nil {
+		msg = err.Error()
+		return
+	}
+
+	// Makes sure the token was actually issued for a magic link.
+	if !claims.VerifyAudience(MagicLinkAudience, true) || claims.Subject == "" || claims.Id == "" {
+		msg = fmt.Sprintf("the token is invalid")
+		return
+	}
+
+	return claims, msg
+}
+
+// Opens the `magic_link` collection, whose token IDs are unique, and deletes links that were never used once expired.
+func OpenMagicLinkCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "magic_link")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Generates the token of a password reset link for `userID`, bound to its current `passwordHash`.
+func GeneratePasswordResetToken(userID, passwordHash string) (signedToken string, err error) {
+	return generatePasswordBoundToken(userID, passwordHash, PasswordResetAudience, PasswordResetMinutes)
+}
+
+// Validates the token of a password reset link and returns its claims and any error message.
+func ValidatePasswordResetToken(signedToken string) (claims *PasswordResetClaims, msg string) {
+	return validatePasswordBoundToken(signedToken, PasswordResetAudience)
+}
+
+// Generates the challenge token handed out by `Login()` when the password of `userID` has expired, bound to its
+// current `passwordHash` so that it can only be used to change the password once.
+func GeneratePasswordChangeToken(userID, passwordHash string) (signedToken string, err error) {
+	return generatePasswordBoundToken(userID, passwordHash, PasswordChangeAudience, PasswordChangeMinutes)
+}
+
+// Validates the challenge token of an expired password and returns its claims and any error message.
+func ValidatePasswordChangeToken(signedToken string) (claims *PasswordResetClaims, msg string) {
+	return validatePasswordBoundToken(signedToken, PasswordChangeAudience)
+}
+
+// Generates a token for `userID` that is only valid for `minutes` and for `audience`, bound to its current
+// `passwordHash` so that it stops working once the password changes.
+func generatePasswordBoundToken(userID, passwordHash, audience string, minutes int) (signedToken string, err error) {
+	claims := &PasswordResetClaims{
+		PasswordHash: HashToken(passwordHash),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   userID,
+			Audience:  audience,
+			ExpiresAt: time.Now().Local().Add(time.Minute * time.Duration(minutes)).Unix(),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+}
+
+// Validates a token bound to a password for `audience` and returns its claims and any error message.
+func validatePasswordBoundToken(signedToken, audience string) (claims *PasswordResetClaims, msg string) {
+	claims = &PasswordResetClaims{}
+
+	// Parses the token using the secret key, which also checks its expiry.
+	_, err := jwt.ParseWithClaims(
+		signedToken,
+		claims,
+		func(token *jwt.Token)(interface{}, error){
+			return []byte(SECRET_KEY), nil
+		},
+	)
+	if err != nil {
+		msg = err.Error()
+		return
+	}
+
+	// Makes sure the token was actually issued for `audience`.
+	if !claims.VerifyAudience(audience, true) || claims.Subject == "" || claims.PasswordHash == "" {
+		msg = fmt.Sprintf("the token is invalid")
+		return
+	}
+
+	return claims, msg
+}
+
+// Generates the token of the link accepting the invitation `invitationID`, identified by `tokenID` so that only the
+// last link sent for the invitation works, and valid until `expiresAt`.
+func GenerateInvitationToken(invitationID, tokenID string, expiresAt time.Time) (signedToken string, err error) {
+	claims := &jwt.StandardClaims{
+		Id:        tokenID,
+		Subject:   invitationID,
+		Audience:  InvitationAudience,
+		ExpiresAt: expiresAt.Unix(),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+}
+
+// Validates the token of an invitation link and returns its claims and any error message.
+func ValidateInvitationToken(signedToken string) (claims *jwt.StandardClaims, msg string) {
+	claims = &jwt.StandardClaims{}
+
+	// Parses the token using the secret key, which also checks its expiry.
+	_, err := jwt.ParseWithClaims(
+		signedToken,
+		claims,
+		func(token *jwt.Token)(interface{}, error){
+			return []byte(SECRET_KEY), nil
+		},
+	)
+	if err != nil {
+		msg = err.Error()
+		return
+	}
+
+	// Makes sure the token was actually issued for an invitation.
+	if !claims.VerifyAudience(InvitationAudience, true) || claims.Subject == "" || claims.Id == "" {
+		msg = fmt.Sprintf("the token is invalid")
+		return
+	}
+
+	return claims, msg
+}
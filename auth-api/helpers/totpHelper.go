@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Number of seconds each TOTP code is valid for (RFC 6238 default).
+const TOTPPeriod = 30
+
+// Number of digits in each TOTP code.
+const TOTPDigits = 6
+
+// Number of time steps before and after the current one that are still accepted, to allow for clock drift.
+const TOTPSkew = 1
+
+// Issuer name shown in authenticator apps, taken from the `TOTP_ISSUER` environment variable.
+var TOTP_ISSUER string = os.Getenv("TOTP_ISSUER")
+
+// Generates a new random 160-bit TOTP secret and returns it as an unpadded base32 string.
+func GenerateTOTPSecret() (secret string, err error) {
+	bytes := make([]byte, 20)
+	if _, err = rand.Read(bytes); err != nil {
+		return
+	}
+
+	secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(bytes)
+	return secret, err
+}
+
+// Returns the `otpauth://` URI used by authenticator apps to register the `secret` for the account `email`.
+func TOTPURI(secret, email string) string {
+	issuer := TOTP_ISSUER
+	if issuer == "" {
+		issuer = "auth-api"
+	}
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(TOTPPeriod))
+
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + email,
+		RawQuery: query.Encode(),
+	}
+
+	return uri.String()
+}
+
+// Encodes the `uri` as a 256x256 QR code PNG image.
+func TOTPQRCode(uri string) ([]byte, error) {
+	return qrcode.Encode(uri, qrcode.Medium, 256)
+}
+
+// Returns the TOTP time step that `t` falls into.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / TOTPPeriod
+}
+
+// Computes the TOTP code of `secret` for the time step `step` as described in RFC 6238 and RFC 4226.
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	// Hashes the big-endian counter with the secret key.
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation of the HMAC to a 31-bit integer.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo), nil
+}
+
+// Validates `code` against `secret` at the time `t`, accepting codes from up to `TOTPSkew` steps away.
+// Returns the matched time step so that callers can reject codes whose step is not newer than `lastUsedStep`,
+// which prevents the same code being replayed within its validity window.
+func ValidateTOTP(secret, code string, t time.Time, lastUsedStep int64) (step int64, ok bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for i := -TOTPSkew; i <= TOTPSkew; i++ {
+		candidate := current + int64(i)
+		if candidate <= lastUsedStep {
+			continue
+		}
+
+		expected, err := TOTPCode(secret, candidate)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return candidate, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,403 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kareem717/auth-api/database"
+	"github.com/kareem717/auth-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Relying party settings, taken from the `WEBAUTHN_RP_ID`, `WEBAUTHN_RP_NAME` and `WEBAUTHN_ORIGIN` environment variables.
+// `WEBAUTHN_ORIGIN` may hold several comma separated origins.
+var WEBAUTHN_RP_ID string = envOrDefault("WEBAUTHN_RP_ID", "localhost")
+var WEBAUTHN_RP_NAME string = envOrDefault("WEBAUTHN_RP_NAME", "auth-api")
+var WEBAUTHN_ORIGIN string = envOrDefault("WEBAUTHN_ORIGIN", "http://localhost:8000")
+
+// Values of the `type` field of the client data for each ceremony.
+const (
+	WebAuthnCeremonyRegister = "webauthn.create"
+	WebAuthnCeremonyLogin    = "webauthn.get"
+)
+
+// Number of minutes a WebAuthn challenge stays valid for.
+const WebAuthnChallengeMinutes = 5
+
+// COSE algorithm identifiers of the supported credential key types.
+const (
+	COSEAlgES256 int64 = -7
+	COSEAlgEdDSA int64 = -8
+	COSEAlgRS256 int64 = -257
+)
+
+// Flags of the authenticator data.
+const (
+	webauthnFlagUserPresent  = 0x01
+	webauthnFlagUserVerified = 0x04
+	webauthnFlagAttestedData = 0x40
+)
+
+// The parsed `clientDataJSON` of a ceremony.
+type webauthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// The parsed authenticator data of a ceremony.
+type webauthnAuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// Returns the value of the environment variable `key`, or `fallback` if it isn't set.
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// Opens the `webauthn_challenge` collection, whose challenges are unique, and deletes challenges that were never used
+// once expired.
+func OpenWebAuthnChallengeCollection() *mongo.Collection {
+	collection := database.OpenCollection(database.Client, "webauthn_challenge")
+
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "challenge", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return collection
+}
+
+// Generates a random 256-bit challenge and returns it base64url encoded, as it appears in the client data.
+func GenerateWebAuthnChallenge() (string, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(challenge), nil
+}
+
+// Returns the challenge embedded in `clientDataJSON`, so that the matching stored challenge can be looked up.
+func WebAuthnClientDataChallenge(clientDataJSON []byte) string {
+	var clientData webauthnClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return ""
+	}
+
+	return clientData.Challenge
+}
+
+// Verifies a registration ceremony against `challenge` and returns the credential to store.
+func VerifyWebAuthnRegistration(clientDataJSON, attestationObject []byte, challenge string) (*models.WebAuthnCredential, error) {
+	if err := verifyWebAuthnClientData(clientDataJSON, WebAuthnCeremonyRegister, challenge); err != nil {
+		return nil, err
+	}
+
+	// Decodes the attestation object into its format, statement and authenticator data.
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("the attestation object is invalid")
+	}
+	format, _ := object["fmt"].(string)
+	statement, _ := object["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := object["authData"].([]byte)
+	if statement == nil || rawAuthData == nil {
+		return nil, errors.New("the attestation object is invalid")
+	}
+
+	authData, err := parseWebAuthnAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.Flags&webauthnFlagAttestedData == 0 {
+		return nil, errors.New("the authenticator did not return a credential")
+	}
+
+	// Makes sure the credential key can actually be used before storing it.
+	_, algorithm, err := parseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verifies the attestation statement over the authenticator data and the client data hash.
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if err := verifyWebAuthnAttestation(format, statement, signed, authData.PublicKey, algorithm); err != nil {
+		return nil, err
+	}
+
+	return &models.WebAuthnCredential{
+		CredentialID: base64.RawURLEncoding.EncodeToString(authData.CredentialID),
+		PublicKey:    authData.PublicKey,
+		Algorithm:    algorithm,
+		SignCount:    authData.SignCount,
+		AAGUID:       hex.EncodeToString(authData.AAGUID),
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+// Verifies an authentication ceremony made with `credential` against `challenge` and returns the new sign count.
+func VerifyWebAuthnAssertion(credential models.WebAuthnCredential, clientDataJSON, rawAuthData, signature []byte, challenge string) (signCount uint32, err error) {
+	if err = verifyWebAuthnClientData(clientDataJSON, WebAuthnCeremonyLogin, challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := parseWebAuthnAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+
+	// Verifies the signature over the authenticator data and the client data hash with the stored key.
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	if err = verifyCOSESignature(credential.PublicKey, signed, signature); err != nil {
+		return 0, err
+	}
+
+	// A counter that doesn't move forward means the authenticator was probably cloned.
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		return 0, errors.New("the sign count did not increase")
+	}
+
+	return authData.SignCount, nil
+}
+
+// Checks the type, challenge and origin of the client data.
+func verifyWebAuthnClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	var clientData webauthnClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return errors.New("the client data is invalid")
+	}
+
+	if clientData.Type != ceremony {
+		return errors.New("the client data type is invalid")
+	}
+	if challenge == "" || clientData.Challenge != challenge {
+		return errors.New("the challenge does not match")
+	}
+	if clientData.CrossOrigin {
+		return errors.New("cross origin ceremonies are not allowed")
+	}
+
+	for _, origin := range strings.Split(WEBAUTHN_ORIGIN, ",") {
+		if strings.TrimSpace(origin) == clientData.Origin {
+			return nil
+		}
+	}
+
+	return errors.New("the origin is not allowed")
+}
+
+// Parses the authenticator data and checks its relying party ID hash and user presence and verification flags.
+func parseWebAuthnAuthenticatorData(data []byte) (*webauthnAuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("the authenticator data is invalid")
+	}
+
+	authData := &webauthnAuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	rpIDHash := sha256.Sum256([]byte(WEBAUTHN_RP_ID))
+	if !bytes.Equal(authData.RPIDHash, rpIDHash[:]) {
+		return nil, errors.New("the relying party id does not match")
+	}
+
+	// Passkeys replace the password, so the user must both be present and verified by the authenticator.
+	if authData.Flags&webauthnFlagUserPresent == 0 || authData.Flags&webauthnFlagUserVerified == 0 {
+		return nil, errors.New("the user was not verified by the authenticator")
+	}
+
+	// Parses the attested credential data, only present when registering.
+	if authData.Flags&webauthnFlagAttestedData != 0 {
+		rest := data[37:]
+		if len(rest) < 18 {
+			return nil, errors.New("the authenticator data is invalid")
+		}
+		authData.AAGUID = rest[:16]
+		length := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if length == 0 || len(rest) < length {
+			return nil, errors.New("the authenticator data is invalid")
+		}
+		authData.CredentialID = rest[:length]
+		rest = rest[length:]
+
+		// The key is a CBOR map of unknown length, so it is found by decoding it.
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		authData.PublicKey = rest[:len(rest)-len(after)]
+	}
+
+	return authData, nil
+}
+
+// Verifies the attestation statement of the `none` and `packed` formats.
+func verifyWebAuthnAttestation(format string, statement map[interface{}]interface{}, signed, publicKey []byte, algorithm int64) error {
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return errors.New("the attestation statement is invalid")
+		}
+		return nil
+	case "packed":
+		statementAlg, _ := statement["alg"].(int64)
+		signature, _ := statement["sig"].([]byte)
+		if signature == nil {
+			return errors.New("the attestation statement is invalid")
+		}
+
+		// Self attestation is signed by the credential key itself.
+		x5c, _ := statement["x5c"].([]interface{})
+		if len(x5c) == 0 {
+			if statementAlg != algorithm {
+				return errors.New("the attestation algorithm does not match the credential")
+			}
+			return verifyCOSESignature(publicKey, signed, signature)
+		}
+
+		// Full attestation is signed by the attestation certificate of the authenticator.
+		raw, _ := x5c[0].([]byte)
+		certificate, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return errors.New("the attestation certificate is invalid")
+		}
+
+		var signatureAlgorithm x509.SignatureAlgorithm
+		switch statementAlg {
+		case COSEAlgES256:
+			signatureAlgorithm = x509.ECDSAWithSHA256
+		case COSEAlgRS256:
+			signatureAlgorithm = x509.SHA256WithRSA
+		case COSEAlgEdDSA:
+			signatureAlgorithm = x509.PureEd25519
+		default:
+			return errors.New("the attestation algorithm is not supported")
+		}
+
+		if err := certificate.CheckSignature(signatureAlgorithm, signed, signature); err != nil {
+			return errors.New("the attestation signature is invalid")
+		}
+		return nil
+	}
+
+	return errors.New("the attestation format is not supported")
+}
+
+// Parses a COSE encoded public key and returns it alongside its algorithm.
+func parseCOSEKey(data []byte) (crypto.PublicKey, int64, error) {
+	decoded, _, err := decodeCBOR(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("the credential public key is invalid")
+	}
+
+	keyType, _ := key[int64(1)].(int64)
+	algorithm, _ := key[int64(3)].(int64)
+
+	switch {
+	case keyType == 2 && algorithm == COSEAlgES256:
+		curve, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if curve != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("the credential public key is invalid")
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, 0, errors.New("the credential public key is invalid")
+		}
+		return publicKey, algorithm, nil
+	case keyType == 1 && algorithm == COSEAlgEdDSA:
+		curve, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if curve != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("the credential public key is invalid")
+		}
+		return ed25519.PublicKey(x), algorithm, nil
+	case keyType == 3 && algorithm == COSEAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) < 256 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31 {
+			return nil, 0, errors.New("the credential public key is invalid")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, algorithm, nil
+	}
+
+	return nil, 0, errors.New("the credential algorithm is not supported")
+}
+
+// Verifies `signature` over `signed` with the COSE encoded public key `coseKey`.
+func verifyCOSESignature(coseKey, signed, signature []byte) error {
+	publicKey, _, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	hash := sha256.Sum256(signed)
+	switch publicKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(publicKey, hash[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(publicKey, signed, signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature) == nil
+	}
+
+	if !valid {
+		return errors.New("the signature is invalid")
+	}
+
+	return nil
+}
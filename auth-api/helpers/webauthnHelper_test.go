@@ -0,0 +1,259 @@
+package helpers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/kareem717/auth-api/models"
+)
+
+// A key and value of a CBOR map, kept in order so that the encoding is deterministic.
+type cborPair struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Encodes the CBOR head of an item of `major` type with the argument `arg`.
+func encodeCBORHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		return append([]byte{major<<5 | 25}, binary.BigEndian.AppendUint16(nil, uint16(arg))...)
+	case arg <= 0xffffffff:
+		return append([]byte{major<<5 | 26}, binary.BigEndian.AppendUint32(nil, uint32(arg))...)
+	}
+	return append([]byte{major<<5 | 27}, binary.BigEndian.AppendUint64(nil, arg)...)
+}
+
+// Encodes the integers, byte and text strings and maps authenticators produce as CBOR.
+func encodeCBOR(value interface{}) []byte {
+	switch value := value.(type) {
+	case int:
+		if value < 0 {
+			return encodeCBORHead(1, uint64(-1-value))
+		}
+		return encodeCBORHead(0, uint64(value))
+	case []byte:
+		return append(encodeCBORHead(2, uint64(len(value))), value...)
+	case string:
+		return append(encodeCBORHead(3, uint64(len(value))), value...)
+	case []cborPair:
+		encoded := encodeCBORHead(5, uint64(len(value)))
+		for _, pair := range value {
+			encoded = append(encoded, encodeCBOR(pair.Key)...)
+			encoded = append(encoded, encodeCBOR(pair.Value)...)
+		}
+		return encoded
+	}
+	panic("unsupported cbor value")
+}
+
+// A software authenticator holding a single P-256 credential.
+type softwareAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+}
+
+// Creates an authenticator with a new P-256 key.
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softwareAuthenticator{key: key, credentialID: credentialID}
+}
+
+// Returns the COSE encoding of the credential's public key.
+func (authenticator *softwareAuthenticator) coseKey() []byte {
+	return encodeCBOR([]cborPair{
+		{1, 2},
+		{3, int(COSEAlgES256)},
+		{-1, 1},
+		{-2, authenticator.key.X.FillBytes(make([]byte, 32))},
+		{-3, authenticator.key.Y.FillBytes(make([]byte, 32))},
+	})
+}
+
+// Returns authenticator data for `rpID` with `flags`, including the credential when registering.
+func (authenticator *softwareAuthenticator) authenticatorData(rpID string, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, authenticator.signCount)
+
+	if flags&webauthnFlagAttestedData != 0 {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(authenticator.credentialID)))
+		data = append(data, authenticator.credentialID...)
+		data = append(data, authenticator.coseKey()...)
+	}
+
+	return data
+}
+
+// Signs the authenticator data and the hash of the client data, as authenticators do.
+func (authenticator *softwareAuthenticator) sign(t *testing.T, authData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	hash := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, authenticator.key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signature
+}
+
+// Returns the client data a browser at `origin` produces for `ceremony` and `challenge`.
+func webauthnTestClientData(t *testing.T, ceremony, challenge, origin string) []byte {
+	clientDataJSON, err := json.Marshal(webauthnClientData{Type: ceremony, Challenge: challenge, Origin: origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return clientDataJSON
+}
+
+// Tests registering a credential with a self attested `packed` statement.
+func TestVerifyWebAuthnRegistration(t *testing.T) {
+	challenge, err := GenerateWebAuthnChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified := byte(webauthnFlagUserPresent | webauthnFlagUserVerified | webauthnFlagAttestedData)
+
+	tests := []struct {
+		name      string
+		challenge string
+		origin    string
+		rpID      string
+		flags     byte
+		valid     bool
+	}{
+		{"valid", challenge, WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, verified, true},
+		{"wrong challenge", "another-challenge", WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, verified, false},
+		{"wrong origin", challenge, "https://attacker.example", WEBAUTHN_RP_ID, verified, false},
+		{"wrong relying party id", challenge, WEBAUTHN_ORIGIN, "attacker.example", verified, false},
+		{"user not verified", challenge, WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, webauthnFlagUserPresent | webauthnFlagAttestedData, false},
+		{"no credential", challenge, WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, webauthnFlagUserPresent | webauthnFlagUserVerified, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			authenticator := newSoftwareAuthenticator(t)
+			clientDataJSON := webauthnTestClientData(t, WebAuthnCeremonyRegister, test.challenge, test.origin)
+			authData := authenticator.authenticatorData(test.rpID, test.flags)
+			attestationObject := encodeCBOR([]cborPair{
+				{"fmt", "packed"},
+				{"attStmt", []cborPair{{"alg", int(COSEAlgES256)}, {"sig", authenticator.sign(t, authData, clientDataJSON)}}},
+				{"authData", authData},
+			})
+
+			credential, err := VerifyWebAuthnRegistration(clientDataJSON, attestationObject, challenge)
+			if (err == nil) != test.valid {
+				t.Fatalf("got error %v, want valid %v", err, test.valid)
+			}
+			if !test.valid {
+				return
+			}
+
+			if credential.CredentialID != base64.RawURLEncoding.EncodeToString(authenticator.credentialID) || credential.Algorithm != COSEAlgES256 {
+				t.Fatalf("got credential %q with algorithm %d", credential.CredentialID, credential.Algorithm)
+			}
+		})
+	}
+
+	// A self attestation signed by another key is rejected.
+	authenticator := newSoftwareAuthenticator(t)
+	clientDataJSON := webauthnTestClientData(t, WebAuthnCeremonyRegister, challenge, WEBAUTHN_ORIGIN)
+	authData := authenticator.authenticatorData(WEBAUTHN_RP_ID, verified)
+	attestationObject := encodeCBOR([]cborPair{
+		{"fmt", "packed"},
+		{"attStmt", []cborPair{{"alg", int(COSEAlgES256)}, {"sig", newSoftwareAuthenticator(t).sign(t, authData, clientDataJSON)}}},
+		{"authData", authData},
+	})
+	if _, err := VerifyWebAuthnRegistration(clientDataJSON, attestationObject, challenge); err == nil {
+		t.Fatal("an attestation signed by another key was accepted")
+	}
+}
+
+// Tests signing in with a registered credential.
+func TestVerifyWebAuthnAssertion(t *testing.T) {
+	challenge, err := GenerateWebAuthnChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified := byte(webauthnFlagUserPresent | webauthnFlagUserVerified)
+
+	tests := []struct {
+		name          string
+		origin        string
+		rpID          string
+		flags         byte
+		storedCount   uint32
+		signCount     uint32
+		otherKey      bool
+		valid         bool
+		wantSignCount uint32
+	}{
+		{"valid", WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, verified, 4, 5, false, true, 5},
+		{"authenticator without a counter", WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, verified, 0, 0, false, true, 0},
+		{"wrong origin", "https://attacker.example", WEBAUTHN_RP_ID, verified, 4, 5, false, false, 0},
+		{"wrong relying party id", WEBAUTHN_ORIGIN, "attacker.example", verified, 4, 5, false, false, 0},
+		{"same sign count", WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, verified, 5, 5, false, false, 0},
+		{"decreasing sign count", WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, verified, 5, 4, false, false, 0},
+		{"counter reset to zero", WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, verified, 5, 0, false, false, 0},
+		{"user not verified", WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, webauthnFlagUserPresent, 4, 5, false, false, 0},
+		{"user not present", WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, webauthnFlagUserVerified, 4, 5, false, false, 0},
+		{"signed by another key", WEBAUTHN_ORIGIN, WEBAUTHN_RP_ID, verified, 4, 5, true, false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			authenticator := newSoftwareAuthenticator(t)
+			credential := models.WebAuthnCredential{
+				CredentialID: base64.RawURLEncoding.EncodeToString(authenticator.credentialID),
+				PublicKey:    authenticator.coseKey(),
+				Algorithm:    COSEAlgES256,
+				SignCount:    test.storedCount,
+			}
+
+			authenticator.signCount = test.signCount
+			clientDataJSON := webauthnTestClientData(t, WebAuthnCeremonyLogin, challenge, test.origin)
+			authData := authenticator.authenticatorData(test.rpID, test.flags)
+			signer := authenticator
+			if test.otherKey {
+				signer = newSoftwareAuthenticator(t)
+			}
+
+			signCount, err := VerifyWebAuthnAssertion(credential, clientDataJSON, authData, signer.sign(t, authData, clientDataJSON), challenge)
+			if (err == nil) != test.valid || signCount != test.wantSignCount {
+				t.Fatalf("got sign count %d and error %v, want sign count %d and valid %v", signCount, err, test.wantSignCount, test.valid)
+			}
+		})
+	}
+
+	// Client data of a registration isn't accepted to sign in.
+	authenticator := newSoftwareAuthenticator(t)
+	credential := models.WebAuthnCredential{PublicKey: authenticator.coseKey(), Algorithm: COSEAlgES256}
+	clientDataJSON := webauthnTestClientData(t, WebAuthnCeremonyRegister, challenge, WEBAUTHN_ORIGIN)
+	authData := authenticator.authenticatorData(WEBAUTHN_RP_ID, verified)
+	if _, err := VerifyWebAuthnAssertion(credential, clientDataJSON, authData, authenticator.sign(t, authData, clientDataJSON), challenge); err == nil {
+		t.Fatal("the client data of a registration was accepted to sign in")
+	}
+}
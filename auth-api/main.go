@@ -1,8 +1,11 @@
 package main
 
 import (
+	"github.com/kareem717/auth-api/helpers"
 	"github.com/kareem717/auth-api/routes"
+	"log"
 	"os"
+	"strings"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,10 +22,40 @@ func main() {
 	// Log all incoming requests.
 	router.Use(gin.Logger())
 
-	// Set up all routes.
+	// Only trusts the client IP forwarded in the `X-Forwarded-For` and `X-Real-IP` headers by the proxies listed in
+	// `TRUSTED_PROXIES`, a comma separated list of IP addresses and CIDR ranges. Without it the address of the peer is
+	// used, as otherwise any client could pick the IP the rate limits, audit log, device checks and policies see.
+	if err := router.SetTrustedProxies(trustedProxies(os.Getenv("TRUSTED_PROXIES"))); err != nil {
+		log.Fatal(err)
+	}
+
+	// Set up all routes. `UserRoutes` makes every route registered after it require an authenticated user, so the
+	// public `AuthRoutes` come before it and every other group of routes after it.
 	routes.AuthRoutes(router)
 	routes.UserRoutes(router)
+	routes.AuditRoutes(router)
+	routes.RoleRoutes(router)
+	routes.PolicyRoutes(router)
+	routes.OrganizationRoutes(router)
+	routes.GroupRoutes(router)
+	routes.ServiceAccountRoutes(router)
+	routes.OAuthRoutes(router)
+
+	// Periodically sign checkpoints of the audit chain.
+	helpers.StartAuditCheckpoints()
 
 	// Run server at port `port`
 	router.Run(":" + port)
-}
\ No newline at end of file
+}
+
+// Parses the comma separated `TRUSTED_PROXIES`, which trusts no proxy when empty.
+func trustedProxies(value string) []string {
+	proxies := []string{}
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
@@ -20,6 +20,28 @@ func Authenticate() gin.HandlerFunc {
 			return
 		}
 
+		// API keys of service accounts are told apart from JWTs by their prefix, and act with the live permissions
+		// of the service account, limited to the scopes of the key.
+		if helpers.IsAPIKey(clientToken) {
+			apiKey, user, err := helpers.AuthenticateAPIKey(clientToken, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error":err.Error()})
+				c.Abort()
+				return
+			}
+
+			c.Set("email", "")
+			c.Set("first_name", *user.FirstName)
+			c.Set("last_name", "")
+			c.Set("user_id", user.UserID)
+			c.Set("user_type", *user.UserType)
+			c.Set("scope", apiKey.Scope)
+			c.Set("tenant", "")
+			c.Set("api_key_id", apiKey.KeyID)
+			c.Next()
+			return
+		}
+
 		// Validate the token using the `ValidateToken()` function
  		claims, err := helpers.ValidateToken(clientToken)
 		// Error handling for the above function.
@@ -35,6 +57,12 @@ func Authenticate() gin.HandlerFunc {
 		c.Set("last_name", claims.LastName)
 		c.Set("user_id", claims.UID)
 		c.Set("user_type", claims.UserType)
+		c.Set("scope", claims.Scope)
+		c.Set("tenant", claims.Tenant)
+		// Tokens issued before permissions were embedded leave them to be looked up.
+		if claims.Permissions != nil {
+			c.Set("permissions", claims.Permissions)
+		}
 		c.Next()
 	}
 }
\ No newline at end of file
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Refuses requests whose authenticated user the policy doesn't allow to perform `permission`. It must run after `Authenticate()`.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkPermission(c, permission) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Refuses requests unless both their access token is scoped for `permission` and their authenticated user is allowed
+// to perform it, as `RequireScopes()` and `RequirePermission()` do. It must run after `Authenticate()`.
+func RequireAccess(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkScopes(c, []string{permission}) || !checkPermission(c, permission) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Returns whether the policy allows the authenticated user of the request to perform `permission`, refusing the
+// request otherwise.
+func checkPermission(c *gin.Context, permission string) bool {
+	if err := helpers.Authorize(c, permission, models.PolicyResource{}); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "required_permission": permission})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
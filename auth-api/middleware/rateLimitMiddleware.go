@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+)
+
+// A rate limit applied to every request whose `Key` is not empty, e.g. "at most 5 login attempts per email per minute".
+type RateLimitRule struct {
+	// Name of the rule, which namespaces its counters in the store.
+	Name string
+	// Maximum number of requests allowed per `Window`.
+	Limit int
+	// Length of the sliding window.
+	Window time.Duration
+	// Returns the value requests are grouped by, an empty string skips the rule for the request.
+	Key func(c *gin.Context) string
+}
+
+// Returns a rule limiting requests per client IP address.
+func RateLimitByIP(name string, limit int, window time.Duration) RateLimitRule {
+	return RateLimitRule{
+		Name:   name + ":ip",
+		Limit:  limit,
+		Window: window,
+		Key: func(c *gin.Context) string {
+			return c.ClientIP()
+		},
+	}
+}
+
+// Returns a rule limiting requests per `email` field of the JSON body.
+func RateLimitByEmail(name string, limit int, window time.Duration) RateLimitRule {
+	return RateLimitRule{
+		Name:   name + ":email",
+		Limit:  limit,
+		Window: window,
+		Key: func(c *gin.Context) string {
+			var body struct {
+				Email string `json:"email"`
+			}
+
+			// Reads the body and puts it back, so the handler can still bind it.
+			raw, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+			if err != nil {
+				return ""
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+			if err := json.Unmarshal(raw, &body); err != nil {
+				return ""
+			}
+
+			return strings.ToLower(strings.TrimSpace(body.Email))
+		},
+	}
+}
+
+// Returns a rule limiting requests per OAuth client, identified by HTTP Basic authentication, its client assertion
+// or the `client_id` form field.
+func RateLimitByClientID(name string, limit int, window time.Duration) RateLimitRule {
+	return RateLimitRule{
+		Name:   name + ":client",
+		Limit:  limit,
+		Window: window,
+		Key: func(c *gin.Context) string {
+			if clientID, _, ok := c.Request.BasicAuth(); ok {
+				return clientID
+			}
+			if assertion := c.PostForm("client_assertion"); assertion != "" {
+				return helpers.OAuthAssertionClientID(assertion)
+			}
+
+			return c.PostForm("client_id")
+		},
+	}
+}
+
+// Limits requests with the sliding window `rules`, responding with a code 429 status and a `Retry-After` header
+// once any of them is exceeded. Counters are kept in `RateLimitBackend`, so they can be shared between instances.
+func RateLimit(rules ...RateLimitRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		var retryAfter time.Duration
+
+		for _, rule := range rules {
+			key := rule.Key(c)
+			if key == "" {
+				continue
+			}
+
+			wait, err := hitRateLimit(rule, key, now)
+			// Fails open, since an unavailable store shouldn't lock every user out.
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+
+		if retryAfter > 0 {
+			c.Header("Retry-After", fmt.Sprint(int64(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests, try again later."})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Counts a request against `rule` for `key` and returns how long the client has to wait if it is over the limit.
+// The sliding window is approximated from the counts of the current and previous fixed windows, weighting the
+// previous one by how much of it still overlaps the sliding window.
+func hitRateLimit(rule RateLimitRule, key string, now time.Time) (time.Duration, error) {
+	current := now.Truncate(rule.Window)
+	previous := current.Add(-rule.Window)
+	elapsed := now.Sub(current)
+	storeKey := rule.Name + ":" + key
+
+	// Counters live for two windows, as they are still needed as the previous window.
+	currentCount, err := RateLimitBackend.Increment(storeKey, current, 2*rule.Window)
+	if err != nil {
+		return 0, err
+	}
+	previousCount, err := RateLimitBackend.Count(storeKey, previous)
+	if err != nil {
+		return 0, err
+	}
+
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+	limit := float64(rule.Limit)
+	if float64(previousCount)*weight+float64(currentCount) <= limit {
+		return 0, nil
+	}
+
+	// Works out when the weighted estimate drops back to the limit.
+	if float64(currentCount) < limit {
+		// Within the current window, once enough of the previous window has slid out.
+		target := 1 - (limit-float64(currentCount))/float64(previousCount)
+		return time.Duration(target*float64(rule.Window)) - elapsed, nil
+	}
+
+	// Otherwise only once enough of the current window has slid out during the next one.
+	target := 1 - limit/float64(currentCount)
+	return rule.Window - elapsed + time.Duration(target*float64(rule.Window)), nil
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kareem717/auth-api/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Stores the per window request counters of the `RateLimit()` middleware.
+type RateLimitStore interface {
+	// Increments the counter of `key` for the window starting at `window` and returns its new value.
+	// The counter may be discarded once `ttl` has passed.
+	Increment(key string, window time.Time, ttl time.Duration) (int, error)
+	// Returns the counter of `key` for the window starting at `window`.
+	Count(key string, window time.Time) (int, error)
+}
+
+// Store used by the `RateLimit()` middleware, selected with the `RATE_LIMIT_BACKEND` environment variable.
+// `memory` (the default) keeps counters per instance, `mongo` shares them between every instance of the service.
+var RateLimitBackend RateLimitStore = newRateLimitStore(os.Getenv("RATE_LIMIT_BACKEND"))
+
+// Returns the store for the backend `name`.
+func newRateLimitStore(name string) RateLimitStore {
+	if name == "mongo" {
+		return NewMongoRateLimitStore(database.OpenCollection(database.Client, "rate_limit"))
+	}
+
+	return NewMemoryRateLimitStore()
+}
+
+// An in-memory `RateLimitStore`, only suitable when a single instance of the service is running.
+type MemoryRateLimitStore struct {
+	mutex     sync.Mutex
+	counters  map[string]memoryRateLimitCounter
+	lastSweep time.Time
+}
+
+// A counter of the `MemoryRateLimitStore`.
+type memoryRateLimitCounter struct {
+	count     int
+	expiresAt time.Time
+}
+
+// Creates an empty `MemoryRateLimitStore`.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{counters: map[string]memoryRateLimitCounter{}, lastSweep: time.Now()}
+}
+
+// Increments the counter of `key` for the window starting at `window` and returns its new value.
+func (store *MemoryRateLimitStore) Increment(key string, window time.Time, ttl time.Duration) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+
+	// Drops expired counters at most once a minute, so memory doesn't grow with every client ever seen.
+	if now.Sub(store.lastSweep) > time.Minute {
+		for id, counter := range store.counters {
+			if now.After(counter.expiresAt) {
+				delete(store.counters, id)
+			}
+		}
+		store.lastSweep = now
+	}
+
+	id := rateLimitCounterKey(key, window)
+	counter := store.counters[id]
+	counter.count++
+	counter.expiresAt = window.Add(ttl)
+	store.counters[id] = counter
+
+	return counter.count, nil
+}
+
+// Returns the counter of `key` for the window starting at `window`.
+func (store *MemoryRateLimitStore) Count(key string, window time.Time) (int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.counters[rateLimitCounterKey(key, window)].count, nil
+}
+
+// Returns the map key of the counter of `key` for the window starting at `window`.
+func rateLimitCounterKey(key string, window time.Time) string {
+	return fmt.Sprintf("%s@%d", key, window.UnixNano())
+}
+
+// A `RateLimitStore` backed by a MongoDB collection, shared between every instance of the service.
+// A TTL index on the `expiresat` field lets MongoDB remove old counters.
+type MongoRateLimitStore struct {
+	collection *mongo.Collection
+}
+
+// Creates a `MongoRateLimitStore` that keeps its counters in `collection`.
+func NewMongoRateLimitStore(collection *mongo.Collection) *MongoRateLimitStore {
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Lets MongoDB delete counters once they expire, failures are harmless as the index may already exist.
+	collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresat", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	return &MongoRateLimitStore{collection: collection}
+}
+
+// Increments the counter of `key` for the window starting at `window` and returns its new value.
+func (store *MongoRateLimitStore) Increment(key string, window time.Time, ttl time.Duration) (int, error) {
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var counter struct {
+		Count int
+	}
+
+	// Atomically creates or increments the counter and returns the updated document.
+	err := store.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": rateLimitCounterKey(key, window)},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "count", Value: 1}}},
+			{Key: "$setOnInsert", Value: bson.D{{Key: "expiresat", Value: window.Add(ttl)}}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+
+	return counter.Count, err
+}
+
+// Returns the counter of `key` for the window starting at `window`.
+func (store *MongoRateLimitStore) Count(key string, window time.Time) (int, error) {
+	// Creates a context with a timeout of 10 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var counter struct {
+		Count int
+	}
+
+	err := store.collection.FindOne(ctx, bson.M{"_id": rateLimitCounterKey(key, window)}).Decode(&counter)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+
+	return counter.Count, err
+}
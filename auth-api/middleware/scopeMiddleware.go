@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/helpers"
+)
+
+// Refuses requests whose access token isn't scoped for every one of `scopes`. It must run after `Authenticate()`.
+// Tokens issued before scopes existed carry none and are unrestricted.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkScopes(c, scopes) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Returns whether the access token of the request is scoped for every one of `scopes`, refusing the request otherwise.
+func checkScopes(c *gin.Context, scopes []string) bool {
+	granted, err := helpers.ParseScope(c.GetString("scope"))
+	if err != nil || !helpers.ScopeCovers(granted, scopes) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "the token isn't scoped for this resource.", "required_scope": strings.Join(scopes, " ")})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A long-lived credential a service account authenticates with, stored in the `api_key` collection. Only a hash of
+// the key is kept, the key itself is shown once when it is created.
+type APIKey struct {
+	ID    primitive.ObjectID `bson:"_id" json:"id"`
+	KeyID string             `json:"key_id"`
+	// Service account the key authenticates as.
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// Start of the key, which identifies it without revealing it.
+	Prefix string `json:"prefix"`
+	// SHA-256 hash of the whole key.
+	Hash string `json:"-"`
+	// Space separated scopes the key is limited to.
+	Scope      string    `json:"scope"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	LastUsedIP string    `json:"last_used_ip"`
+	// User who created the key.
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	// Time the key was revoked, zero while it is usable.
+	RevokedAt time.Time `json:"revoked_at"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Types of the recorded audit events.
+const (
+	AuditLoginSuccess         = "login.success"
+	AuditLoginFailure         = "login.failure"
+	AuditNewDevice            = "login.new_device"
+	AuditSuspiciousLogin      = "login.suspicious"
+	AuditSignUp               = "signup"
+	AuditTokenRefresh         = "token.refresh"
+	AuditPasswordChange       = "password.change"
+	AuditPasswordReset        = "password.reset"
+	AuditRoleChange           = "role.change"
+	AuditRoleCreate           = "role.create"
+	AuditAccountUnlock        = "account.unlock"
+	AuditOrgCreate            = "org.create"
+	AuditOrgMembership        = "org.membership"
+	AuditGroupChange          = "group.change"
+	AuditOrgInvitation        = "org.invitation"
+	AuditServiceAccountCreate = "service_account.create"
+	AuditAPIKeyCreate         = "api_key.create"
+	AuditAPIKeyRevoke         = "api_key.revoke"
+	AuditOAuthClientCreate    = "oauth.client_create"
+	AuditOAuthConsent         = "oauth.consent"
+	AuditOAuthToken           = "oauth.token"
+	AuditOAuthDevice          = "oauth.device"
+	AuditAdminReadUser        = "admin.read_user"
+	AuditAdminListUsers       = "admin.list_users"
+	AuditAdminReadAudit       = "admin.read_audit"
+)
+
+// Outcomes of the recorded audit events.
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// A security relevant event, stored in the append-only `audit_log` collection.
+// Events form a hash chain: each one records the hash of the event before it.
+type AuditEvent struct {
+	ID primitive.ObjectID `bson:"_id" json:"id"`
+	// Position of the event in the chain, starting at 1.
+	Sequence int64 `json:"sequence"`
+	// `Hash` of the previous event, or `AuditGenesisHash` for the first one.
+	PrevHash string `json:"prev_hash"`
+	// SHA-256 hash of every other field of the event, including `PrevHash`.
+	Hash    string `json:"hash"`
+	Type    string `json:"type"`
+	Outcome string `json:"outcome"`
+	// `user_id` of the user who performed the action, empty for anonymous callers.
+	ActorID string `json:"actor_id"`
+	// `user_id` of the user the action was performed on.
+	TargetID  string            `json:"target_id"`
+	IP        string            `json:"ip"`
+	UserAgent string            `json:"user_agent"`
+	Details   map[string]string `json:"details,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// `PrevHash` of the first event of the chain.
+const AuditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// A signed record of the head of the audit chain, stored in the `audit_checkpoint` collection.
+// Rewriting the chain before a checkpoint would require the service's signing key.
+type AuditCheckpoint struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Sequence  int64              `json:"sequence"`
+	Hash      string             `json:"hash"`
+	CreatedAt time.Time          `json:"created_at"`
+	// JWT signed with the service's key, whose claims hold `Sequence` and `Hash`.
+	Signature string `json:"signature"`
+}
+
+// Result of walking the audit chain.
+type AuditChainReport struct {
+	Valid              bool  `json:"valid"`
+	RecordsChecked     int64 `json:"records_checked"`
+	CheckpointsChecked int   `json:"checkpoints_checked"`
+	// Sequence and ID of the first record that breaks the chain, when it is not valid.
+	BrokenAtSequence int64  `json:"broken_at_sequence,omitempty"`
+	BrokenRecordID   string `json:"broken_record_id,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+}
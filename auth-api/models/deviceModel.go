@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// A device a user has signed in from.
+type KnownDevice struct {
+	// Hash of the user agent and network prefix the device signed in from.
+	Fingerprint string `json:"fingerprint"`
+	// Hash of the device cookie, when the device kept it.
+	DeviceHash  string    `json:"-"`
+	UserAgent   string    `json:"user_agent"`
+	IPPrefix    string    `json:"ip_prefix"`
+	Country     string    `json:"country"`
+	City        string    `json:"city"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// Where, and when, a sign in came from, as resolved from the GeoIP database.
+type LoginLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Radius around the coordinates the sign in came from, in kilometers.
+	AccuracyRadius float64   `json:"accuracy_radius"`
+	Country        string    `json:"country"`
+	City           string    `json:"city"`
+	At             time.Time `json:"at"`
+}
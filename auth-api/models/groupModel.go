@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ways a user can come to hold a role.
+const (
+	// The role was assigned to the user itself.
+	RoleSourceDirect = "direct"
+	// The role is named after the user's legacy `user_type`.
+	RoleSourceUserType = "user_type"
+	// The role was assigned to a group the user belongs to, directly or through nested groups.
+	RoleSourceGroup = "group"
+)
+
+// A named set of users and other groups, stored in the `group` collection. Its members inherit its roles, along
+// with the roles of every group it is itself a member of.
+type Group struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	GroupID     string             `json:"group_id"`
+	Name        string             `json:"name" validate:"required,min=2,max=50"`
+	Description string             `json:"description" validate:"max=200"`
+	Roles       []string           `json:"roles" validate:"dive,required"`
+	// Groups this group is a member of, whose roles it inherits.
+	Groups    []string  `json:"groups"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// A role held by a user, with how the user came to hold it.
+type RoleGrant struct {
+	Role   string `json:"role"`
+	Source string `json:"source"`
+	// Groups the role was inherited through, from the group the user belongs to up to the group holding the role.
+	GroupPath []string `json:"group_path,omitempty"`
+}
+
+// A permission of a role held by a user, which grants a requested permission.
+type PermissionGrant struct {
+	// Permission of the role, which may be a wildcard covering the requested permission.
+	Permission string `json:"permission"`
+	RoleGrant
+}
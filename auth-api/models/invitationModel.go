@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of an invitation.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	// Reported for pending invitations whose link has expired, it is never stored so they can still be resent.
+	InvitationExpired = "expired"
+)
+
+// An invitation of an email address to join an organization with pre-set roles, stored in the `invitation` collection.
+type Invitation struct {
+	ID           primitive.ObjectID `bson:"_id" json:"id"`
+	InvitationID string             `json:"invitation_id"`
+	OrgID        string             `json:"org_id"`
+	Email        string             `json:"email" validate:"required,email"`
+	Roles        []string           `json:"roles" validate:"dive,required"`
+	Status       string             `json:"status"`
+	// User who created the invitation.
+	InvitedBy string `json:"invited_by"`
+	// ID of the only link that can accept the invitation, replaced whenever it is resent.
+	TokenID   string `json:"-"`
+	SentCount int    `json:"sent_count"`
+	// User who accepted the invitation.
+	AcceptedBy string    `json:"accepted_by,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// An issued, not yet redeemed, magic sign-in link.
+type MagicLink struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	TokenID   string             `json:"token_id"`
+	UserID    string             `json:"user_id"`
+	ExpiresAt time.Time          `json:"expires_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// An issued, not yet completed, MFA step of a login.
+type MFAChallenge struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	ChallengeID string             `json:"challenge_id"`
+	UserID      string             `json:"user_id"`
+	// Number of codes submitted for the challenge so far.
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
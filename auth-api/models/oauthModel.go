@@ -0,0 +1,143 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Types of OAuth clients.
+const (
+	// A client that can't keep a secret, such as a single page or native app, which relies on PKCE alone.
+	OAuthClientPublic = "public"
+	// A client running on a server, which authenticates with its secret at the token endpoint.
+	OAuthClientConfidential = "confidential"
+)
+
+// Grants an OAuth client can exchange for tokens.
+const (
+	// Exchanging an authorization code a user approved, to act on the user's behalf.
+	OAuthGrantAuthorizationCode = "authorization_code"
+	// Authenticating as the client itself, to act on its own behalf.
+	OAuthGrantClientCredentials = "client_credentials"
+	// Exchanging the refresh token of an earlier grant for new tokens.
+	OAuthGrantRefreshToken = "refresh_token"
+	// Polling for the tokens of a device code a user approved on another device.
+	OAuthGrantDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// Statuses of a device authorization.
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+// Ways OAuth clients authenticate at the token endpoint.
+const (
+	// Public clients only identify themselves with their client ID.
+	OAuthAuthNone = "none"
+	// The client secret, with HTTP Basic authentication or as a form field.
+	OAuthAuthClientSecret = "client_secret_basic"
+	// A JWT assertion signed with the private key matching the registered public key.
+	OAuthAuthPrivateKeyJWT = "private_key_jwt"
+)
+
+// OpenID Connect scopes, which grant no permission but decide what the ID token and userinfo tell about the user.
+const (
+	// Asks for an ID token.
+	ScopeOpenID = "openid"
+	// The user's names and when the profile was last updated.
+	ScopeProfile = "profile"
+	// The user's email address.
+	ScopeEmail = "email"
+	// The user's phone number.
+	ScopePhone = "phone"
+)
+
+// An application allowed to request tokens on behalf of users or of itself, stored in the `oauth_client` collection.
+type OAuthClient struct {
+	ID       primitive.ObjectID `bson:"_id" json:"id"`
+	ClientID string             `json:"client_id"`
+	Name     string             `json:"name"`
+	Type     string             `json:"type"`
+	// Grants the client may use, only the authorization code grant when empty.
+	GrantTypes []string `json:"grant_types"`
+	// How the client authenticates at the token endpoint.
+	TokenEndpointAuthMethod string `json:"token_endpoint_auth_method"`
+	// Addresses the users can be sent back to with an authorization code, matched exactly.
+	RedirectURIs []string `json:"redirect_uris"`
+	// Scopes the client may request, which it is granted when it doesn't ask for any.
+	Scopes []string `json:"scopes"`
+	// Whether the client is one of our own applications, whose users don't have to consent.
+	FirstParty bool `json:"first_party"`
+	// PEM encoded RSA or ECDSA public key verifying the assertions of a `private_key_jwt` client.
+	PublicKey string `json:"public_key,omitempty"`
+	// SHA-256 hash of the secret of a `client_secret_basic` client.
+	SecretHash string    `json:"-"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// A single-use authorization code, stored in the `oauth_code` collection until it is exchanged or expires.
+type OAuthCode struct {
+	ID primitive.ObjectID `bson:"_id"`
+	// SHA-256 hash of the code.
+	CodeHash string
+	ClientID string
+	UserID   string
+	// Redirect URI given in the authorization request, empty when it was omitted.
+	RedirectURI string
+	Scope       string
+	Tenant      string
+	// OpenID Connect nonce of the client, repeated in the ID token.
+	Nonce string
+	// S256 PKCE challenge the code verifier must match.
+	CodeChallenge string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+}
+
+// The tokens a client holds on behalf of a user, stored in the `oauth_grant` collection. Each client has its own
+// refresh token, apart from the one the user signs in with, so neither can invalidate the other.
+type OAuthGrant struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	UserID   string
+	ClientID string
+	// SHA-256 hash of the only refresh token of the grant that can still be redeemed.
+	RefreshTokenHash string
+	Scope            string
+	Tenant           string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// The scopes a user allowed a client to request on its behalf, stored in the `oauth_consent` collection.
+type OAuthConsent struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    string             `json:"user_id"`
+	ClientID  string             `json:"client_id"`
+	Scopes    []string           `json:"scopes"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// A device authorization, stored in the `oauth_device_code` collection until the device redeems it or it expires.
+type OAuthDeviceCode struct {
+	ID primitive.ObjectID `bson:"_id"`
+	// SHA-256 hash of the device code the device polls with.
+	DeviceCodeHash string
+	// Code the user enters on another device, stored without its dash.
+	UserCode string
+	ClientID string
+	Scope    string
+	Status   string
+	// User who approved or denied the authorization, and the organization the tokens act within.
+	UserID string
+	Tenant string
+	// Number of seconds the device must wait between polls, which grows when it polls too fast.
+	Interval     int
+	LastPolledAt time.Time
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
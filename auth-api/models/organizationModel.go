@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ways an organization can require email addresses to be unique.
+const (
+	// The email is unique among every account that isn't held by an organization with tenant uniqueness, the default.
+	EmailUniquenessGlobal = "global"
+	// The email only has to be unique among the accounts of the organization, so the same person can hold separate
+	// accounts in several organizations. Such accounts sign in by naming the organization.
+	EmailUniquenessTenant = "tenant"
+)
+
+// A tenant of the service, such as one of the products sharing it, stored in the `organization` collection.
+type Organization struct {
+	ID    primitive.ObjectID `bson:"_id" json:"id"`
+	OrgID string             `json:"org_id"`
+	Name  string             `json:"name" validate:"required,min=2,max=100"`
+	// Short unique name of the organization, used in URLs.
+	Slug            string `json:"slug" validate:"required,min=2,max=50,lowercase,alphanum"`
+	EmailUniqueness string `json:"email_uniqueness" validate:"omitempty,eq=global|eq=tenant"`
+	// Whether anyone can sign up to the organization, rather than only the users added by its admins.
+	OpenSignUp bool      `json:"open_sign_up"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// The membership of a user in an organization, with the roles the user holds within it.
+type OrgMembership struct {
+	OrgID    string    `json:"org_id"`
+	Roles    []string  `json:"roles"`
+	JoinedAt time.Time `json:"joined_at"`
+}
@@ -0,0 +1,92 @@
+package models
+
+// Effects of a policy rule.
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
+// Operators a policy condition can compare attributes with.
+const (
+	// The attribute equals, or doesn't equal, the value.
+	PolicyOperatorEquals    = "eq"
+	PolicyOperatorNotEquals = "ne"
+	// The attribute is one of the values of a list.
+	PolicyOperatorIn = "in"
+	// The list attribute contains the value.
+	PolicyOperatorContains = "contains"
+	// The list attribute, of permissions or scopes, grants, or doesn't grant, the permission of the value.
+	PolicyOperatorGrants    = "grants"
+	PolicyOperatorNotGrants = "not_grants"
+	// The attribute is set to a non-empty value.
+	PolicyOperatorExists = "exists"
+)
+
+// A set of rules deciding whether a subject may perform an action on a resource. A request is denied if any deny rule
+// matches it, otherwise it is allowed if any allow rule matches it, and denied by default.
+type Policy struct {
+	Rules []PolicyRule `json:"rules" yaml:"rules"`
+}
+
+// A rule of a policy, which matches a request when its action and resource type are listed and every condition holds.
+type PolicyRule struct {
+	ID     string `json:"id" yaml:"id"`
+	Effect string `json:"effect" yaml:"effect"`
+	// Actions the rule applies to, written like permissions, so `users:*` or `*` can be used.
+	Actions []string `json:"actions" yaml:"actions"`
+	// Resource types the rule applies to, every type when empty.
+	Resources  []string          `json:"resources,omitempty" yaml:"resources"`
+	Conditions []PolicyCondition `json:"conditions,omitempty" yaml:"conditions"`
+}
+
+// A comparison of an attribute of the request, such as `subject.user_id`, against either a literal `Value` or the
+// value of another attribute named by `ValueAttribute`.
+type PolicyCondition struct {
+	Attribute      string      `json:"attribute" yaml:"attribute"`
+	Operator       string      `json:"operator" yaml:"operator"`
+	Value          interface{} `json:"value,omitempty" yaml:"value"`
+	ValueAttribute string      `json:"value_attribute,omitempty" yaml:"value_attribute"`
+}
+
+// The user performing an action, with the roles and permissions it holds and the scopes of its token.
+type PolicySubject struct {
+	UserID      string   `json:"user_id"`
+	UserType    string   `json:"user_type"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	Scopes      []string `json:"scopes"`
+	// Organization the token acts within, with the roles the user holds there and the permissions they grant.
+	Tenant            string   `json:"tenant,omitempty"`
+	TenantRoles       []string `json:"tenant_roles,omitempty"`
+	TenantPermissions []string `json:"tenant_permissions,omitempty"`
+}
+
+// The resource an action is performed on.
+type PolicyResource struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	OwnerID string `json:"owner_id"`
+	// Organization the resource belongs to, empty for resources that belong to no organization.
+	Tenant string `json:"tenant,omitempty"`
+	// Any other attributes of the resource, available to conditions as `resource.<key>`.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// The outcome of evaluating a policy, with the evaluation of every rule when it was explained.
+type PolicyDecision struct {
+	Allowed bool   `json:"allowed"`
+	Action  string `json:"action"`
+	// Rule that decided the outcome, empty when no rule matched and the request was denied by default.
+	RuleID      string                 `json:"rule_id,omitempty"`
+	Reason      string                 `json:"reason"`
+	Evaluations []PolicyRuleEvaluation `json:"evaluations,omitempty"`
+}
+
+// The evaluation of a single rule against a request.
+type PolicyRuleEvaluation struct {
+	RuleID  string `json:"rule_id"`
+	Effect  string `json:"effect"`
+	Matched bool   `json:"matched"`
+	// Why the rule didn't match, when it didn't.
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Permissions checked by the routes, written as `resource:action`. A role can also be granted every action on a
+// resource with `resource:*`, or every permission with `*`.
+const (
+	PermissionAll                  = "*"
+	PermissionUsersRead            = "users:read"
+	PermissionUsersWrite           = "users:write"
+	PermissionRolesRead            = "roles:read"
+	PermissionRolesWrite           = "roles:write"
+	PermissionRolesAssign          = "roles:assign"
+	PermissionAuditRead            = "audit:read"
+	PermissionPolicyRead           = "policy:read"
+	PermissionOrgsRead             = "orgs:read"
+	PermissionOrgsWrite            = "orgs:write"
+	PermissionOrgsMembers          = "orgs:members"
+	PermissionGroupsRead           = "groups:read"
+	PermissionGroupsWrite          = "groups:write"
+	PermissionServiceAccountsRead  = "service_accounts:read"
+	PermissionServiceAccountsWrite = "service_accounts:write"
+	PermissionOAuthClientsRead     = "oauth_clients:read"
+	PermissionOAuthClientsWrite    = "oauth_clients:write"
+)
+
+// Scope of the routes users call on their own account, such as changing their password or MFA. It is only ever
+// checked as a token scope, as every user may manage their own account.
+const ScopeAccount = "account:write"
+
+// Names of the built-in roles, which match the legacy `user_type` values.
+const (
+	RoleAdmin = "ADMIN"
+	RoleUser  = "USER"
+)
+
+// A named set of permissions, stored in the `role` collection.
+type Role struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Name        string             `json:"name" validate:"required,min=2,max=50"`
+	Description string             `json:"description" validate:"max=200"`
+	Permissions []string           `json:"permissions" validate:"required,dive,required"`
+	// Whether the role is one of the built-in roles created at startup.
+	Builtin   bool      `json:"builtin"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -10,13 +10,51 @@ type User struct {
 	ID           primitive.ObjectID `bson:"_id"`
 	FirstName    *string            `json:"first_name" validate:"required,alpha,min=2,max=100"`
 	LastName     *string            `json:"last_name" validate:"required,alpha,min=2,max=100"`
-	Password     *string            `json:"password" validate:"required,min=8"`
+	Password     *string            `json:"password" validate:"required"`
 	Email        *string            `json:"email" validate:"required,email"`
 	Phone        *string            `json:"phone_number" validate:"numeric,min=7,max=15"`
 	Token        *string            `json:"token"`
 	UserType     *string            `json:"user_type" validate:"required,eq=ADMIN|eq=USER"`
+	// Names of the roles granting the user its permissions, on top of the role named after `UserType`.
+	Roles        []string           `json:"roles"`
+	// Groups the user is a direct member of, whose roles it inherits.
+	Groups       []string           `json:"groups"`
+	// Organization whose accounts the email is unique among, empty when it is unique across the service.
+	TenantID     string             `json:"tenant_id"`
+	// Organizations the user belongs to, with the roles it holds in each of them.
+	Memberships  []OrgMembership    `json:"memberships"`
 	RefreshToken *string            `json:"refresh_token"`
 	CreatedAt    time.Time          `json:"created_at"`
 	UpdatedAt    time.Time          `json:"updated_at"`
 	UserID       string             `json:"user_id"`
+	// Whether the user is a service account, which has no password and authenticates with API keys.
+	ServiceAccount bool `json:"service_account"`
+	// Whether the user has confirmed a TOTP authenticator and must pass MFA at login.
+	MFAEnabled bool `json:"mfa_enabled"`
+	// Base32 TOTP secret of the confirmed authenticator, never sent to clients.
+	MFASecret *string `json:"-"`
+	// Base32 TOTP secret awaiting confirmation of its first code.
+	MFAPendingSecret *string `json:"-"`
+	// Last TOTP time step that was accepted, used to reject replayed codes.
+	MFALastUsedStep int64 `json:"-"`
+	// Hashes of the single-use recovery codes that can be used in place of a TOTP code.
+	MFARecoveryCodes []string `json:"-"`
+	// Number of recovery codes left, derived from `MFARecoveryCodes` when the user is returned.
+	MFARecoveryCodesRemaining int `json:"mfa_recovery_codes_remaining" bson:"-"`
+	// WebAuthn credentials (passkeys and security keys) the user can sign in with.
+	WebAuthnCredentials []WebAuthnCredential `json:"-"`
+	// Hashes of the previous passwords, most recent first, that a new password may not match.
+	PasswordHistory []string `json:"-"`
+	// Time the password was last set, used to expire it after the configured maximum age.
+	PasswordChangedAt time.Time `json:"-"`
+	// Consecutive failed password checks since the last successful login or lockout.
+	FailedLoginAttempts int `json:"-"`
+	// Number of times the account was locked since the last successful login, used to escalate the lockout duration.
+	LockoutCount int `json:"-"`
+	// Time until which password logins are refused.
+	LockedUntil time.Time `json:"-"`
+	// Devices the user has signed in from, most recently seen first.
+	KnownDevices []KnownDevice `json:"-"`
+	// Location of the last sign in, used to detect impossible travel.
+	LastLoginLocation *LoginLocation `json:"-"`
 }
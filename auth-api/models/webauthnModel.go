@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A WebAuthn credential (passkey or security key) registered by a user.
+type WebAuthnCredential struct {
+	CredentialID string    `json:"credential_id"`
+	PublicKey    []byte    `json:"-"`
+	Algorithm    int64     `json:"algorithm"`
+	SignCount    uint32    `json:"sign_count"`
+	AAGUID       string    `json:"aaguid"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastUsedAt   time.Time `json:"last_used_at"`
+}
+
+// A single-use challenge issued at the start of a WebAuthn ceremony.
+type WebAuthnChallenge struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Challenge string             `json:"challenge"`
+	Ceremony  string             `json:"ceremony"`
+	UserID    string             `json:"user_id"`
+	ExpiresAt time.Time          `json:"expires_at"`
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/controllers"
+	"github.com/kareem717/auth-api/middleware"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Registers all the types of `AuditRoutes`.
+func AuditRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/audit/events", middleware.RequireAccess(models.PermissionAuditRead), controllers.ListAuditEvents())
+	incomingRoutes.GET("/audit/events/export", middleware.RequireAccess(models.PermissionAuditRead), controllers.ExportAuditEvents())
+	incomingRoutes.GET("/audit/verify", middleware.RequireAccess(models.PermissionAuditRead), controllers.VerifyAuditChain())
+}
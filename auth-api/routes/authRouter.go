@@ -1,13 +1,56 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/kareem717/auth-api/controllers"
+	"github.com/kareem717/auth-api/middleware"
 	"github.com/gin-gonic/gin"
 )
 
 // Registers all the types of `AuthRoutes`
 func AuthRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("users/signup", controllers.SignUp())
-	incomingRoutes.POST("users/login", controllers.Login())
-}
+	// Limits credential checks per client IP and per targeted email, to slow down brute-force and credential stuffing
+	// attacks and keep the expensive password hashing from being used to exhaust the CPU.
+	loginLimit := middleware.RateLimit(
+		middleware.RateLimitByIP("login", 20, time.Minute),
+		middleware.RateLimitByEmail("login", 5, time.Minute),
+	)
+	signupLimit := middleware.RateLimit(
+		middleware.RateLimitByIP("signup", 5, time.Minute),
+		middleware.RateLimitByEmail("signup", 3, time.Minute),
+	)
+	mfaLimit := middleware.RateLimit(middleware.RateLimitByIP("mfa", 10, time.Minute))
+	resetLimit := middleware.RateLimit(middleware.RateLimitByIP("reset", 10, time.Minute))
+
+	incomingRoutes.POST("users/signup", signupLimit, controllers.SignUp())
+	incomingRoutes.POST("users/login", loginLimit, controllers.Login())
+	incomingRoutes.POST("users/login/mfa", mfaLimit, controllers.LoginMFA())
+	incomingRoutes.POST("users/login/webauthn/begin", mfaLimit, controllers.BeginWebAuthnLogin())
+	incomingRoutes.POST("users/login/webauthn/finish", mfaLimit, controllers.FinishWebAuthnLogin())
+	incomingRoutes.POST("users/login/magic-link", loginLimit, controllers.RequestMagicLink())
+	incomingRoutes.GET("users/login/magic-link/callback", controllers.MagicLinkCallback())
+	incomingRoutes.POST("users/password/reset/request", loginLimit, controllers.RequestPasswordReset())
+	incomingRoutes.POST("users/password/reset", resetLimit, controllers.ResetPassword())
+	incomingRoutes.POST("users/login/password-change", resetLimit, controllers.ChangeExpiredPassword())
+	incomingRoutes.POST("users/token/refresh", mfaLimit, controllers.RefreshTokens())
+
+	// Accepting an organization invitation with the emailed link.
+	incomingRoutes.POST("invitations/accept", resetLimit, controllers.AcceptInvitation())
 
+	// OAuth clients exchanging grants for tokens. They are limited per client, with room for every device of a client
+	// polling at its interval, as devices polling too fast are told to slow down by the token endpoint itself.
+	tokenLimit := middleware.RateLimit(middleware.RateLimitByClientID("oauth_token", 600, time.Minute))
+	deviceLimit := middleware.RateLimit(middleware.RateLimitByClientID("oauth_device", 60, time.Minute))
+	incomingRoutes.POST("oauth/token", tokenLimit, controllers.OAuthToken())
+	incomingRoutes.POST("oauth/device_authorization", deviceLimit, controllers.DeviceAuthorization())
+
+	// OpenID Connect discovery, and the claims about the users of access tokens, which are checked by `UserInfo()`.
+	incomingRoutes.GET(".well-known/openid-configuration", controllers.OpenIDConfiguration())
+	incomingRoutes.GET(".well-known/jwks.json", controllers.OpenIDKeys())
+	incomingRoutes.GET("userinfo", controllers.UserInfo())
+	incomingRoutes.POST("userinfo", controllers.UserInfo())
+
+	// One-time promotion of the first admin with the `BOOTSTRAP_ADMIN_TOKEN`.
+	incomingRoutes.POST("setup/admin", resetLimit, controllers.BootstrapAdmin())
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/controllers"
+	"github.com/kareem717/auth-api/middleware"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Registers all the types of `GroupRoutes`.
+func GroupRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/groups", middleware.RequireAccess(models.PermissionGroupsRead), controllers.GetGroups())
+	incomingRoutes.POST("/groups", middleware.RequireAccess(models.PermissionGroupsWrite), controllers.CreateGroup())
+	incomingRoutes.GET("/groups/:group_id", middleware.RequireAccess(models.PermissionGroupsRead), controllers.GetGroup())
+	incomingRoutes.PATCH("/groups/:group_id", middleware.RequireAccess(models.PermissionGroupsWrite), controllers.UpdateGroup())
+	incomingRoutes.DELETE("/groups/:group_id", middleware.RequireAccess(models.PermissionGroupsWrite), controllers.DeleteGroup())
+
+	incomingRoutes.PUT("/groups/:group_id/users/:user_id", middleware.RequireAccess(models.PermissionGroupsWrite), controllers.AddGroupUser())
+	incomingRoutes.DELETE("/groups/:group_id/users/:user_id", middleware.RequireAccess(models.PermissionGroupsWrite), controllers.RemoveGroupUser())
+	incomingRoutes.PUT("/groups/:group_id/groups/:child_id", middleware.RequireAccess(models.PermissionGroupsWrite), controllers.AddGroupChild())
+	incomingRoutes.DELETE("/groups/:group_id/groups/:child_id", middleware.RequireAccess(models.PermissionGroupsWrite), controllers.RemoveGroupChild())
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/controllers"
+	"github.com/kareem717/auth-api/middleware"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Registers all the types of `OAuthRoutes`.
+func OAuthRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/oauth/clients", middleware.RequireAccess(models.PermissionOAuthClientsRead), controllers.GetOAuthClients())
+	incomingRoutes.POST("/oauth/clients", middleware.RequireAccess(models.PermissionOAuthClientsWrite), controllers.CreateOAuthClient())
+
+	// Only a token the user signed in with can authorize clients, not one issued to another client.
+	incomingRoutes.GET("/oauth/authorize", middleware.RequireScopes(models.ScopeAccount), controllers.Authorize())
+	incomingRoutes.POST("/oauth/authorize", middleware.RequireScopes(models.ScopeAccount), controllers.ConsentAuthorization())
+
+	// Users entering the code shown by a device, which is rate limited as the codes are short enough to be guessed.
+	deviceLimit := middleware.RateLimit(middleware.RateLimitByIP("device", 10, time.Minute))
+	incomingRoutes.GET("/oauth/device", deviceLimit, middleware.RequireScopes(models.ScopeAccount), controllers.GetDeviceAuthorization())
+	incomingRoutes.POST("/oauth/device", deviceLimit, middleware.RequireScopes(models.ScopeAccount), controllers.DecideDeviceAuthorization())
+}
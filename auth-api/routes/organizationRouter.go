@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/controllers"
+	"github.com/kareem717/auth-api/middleware"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Registers all the types of `OrganizationRoutes`.
+func OrganizationRoutes(incomingRoutes *gin.Engine) {
+	// Creating and listing every organization is left to the admins of the service.
+	incomingRoutes.POST("/orgs", middleware.RequireAccess(models.PermissionOrgsWrite), controllers.CreateOrganization())
+	incomingRoutes.GET("/orgs", middleware.RequireAccess(models.PermissionOrgsRead), controllers.GetOrganizations())
+
+	// Routes on a single organization, authorized by their controllers so the organization's admins can call them.
+	incomingRoutes.GET("/orgs/:org_id", middleware.RequireScopes(models.PermissionOrgsRead), controllers.GetOrganization())
+	incomingRoutes.PUT("/orgs/:org_id/members/:user_id", middleware.RequireScopes(models.PermissionOrgsMembers), controllers.SetMembership())
+	incomingRoutes.DELETE("/orgs/:org_id/members/:user_id", middleware.RequireScopes(models.PermissionOrgsMembers), controllers.RemoveMembership())
+
+	incomingRoutes.POST("/orgs/:org_id/invitations", middleware.RequireScopes(models.PermissionOrgsMembers), controllers.CreateInvitation())
+	incomingRoutes.GET("/orgs/:org_id/invitations", middleware.RequireScopes(models.PermissionOrgsMembers), controllers.GetInvitations())
+	incomingRoutes.POST("/orgs/:org_id/invitations/:invitation_id/resend", middleware.RequireScopes(models.PermissionOrgsMembers), controllers.ResendInvitation())
+	incomingRoutes.DELETE("/orgs/:org_id/invitations/:invitation_id", middleware.RequireScopes(models.PermissionOrgsMembers), controllers.RevokeInvitation())
+}
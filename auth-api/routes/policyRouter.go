@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/controllers"
+	"github.com/kareem717/auth-api/middleware"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Registers all the types of `PolicyRoutes`.
+func PolicyRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/policy/explain", middleware.RequireAccess(models.PermissionPolicyRead), controllers.ExplainPolicy())
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/controllers"
+	"github.com/kareem717/auth-api/middleware"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Registers all the types of `RoleRoutes`.
+func RoleRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/roles", middleware.RequireAccess(models.PermissionRolesRead), controllers.GetRoles())
+	incomingRoutes.POST("/roles", middleware.RequireAccess(models.PermissionRolesWrite), controllers.CreateRole())
+
+	incomingRoutes.POST("/users/:user_id/roles", middleware.RequireAccess(models.PermissionRolesAssign), controllers.AssignRole())
+	incomingRoutes.DELETE("/users/:user_id/roles/:role", middleware.RequireAccess(models.PermissionRolesAssign), controllers.RemoveRole())
+	incomingRoutes.GET("/users/:user_id/permissions", middleware.RequireAccess(models.PermissionRolesRead), controllers.GetUserPermissions())
+
+	// Promoting and demoting admins requires every permission, which only admins have.
+	incomingRoutes.PUT("/users/:user_id/user-type", middleware.RequireAccess(models.PermissionAll), controllers.SetUserType())
+}
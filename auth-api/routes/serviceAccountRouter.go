@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kareem717/auth-api/controllers"
+	"github.com/kareem717/auth-api/middleware"
+	"github.com/kareem717/auth-api/models"
+)
+
+// Registers all the types of `ServiceAccountRoutes`.
+func ServiceAccountRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/service-accounts", middleware.RequireAccess(models.PermissionServiceAccountsRead), controllers.GetServiceAccounts())
+	incomingRoutes.POST("/service-accounts", middleware.RequireAccess(models.PermissionServiceAccountsWrite), controllers.CreateServiceAccount())
+
+	incomingRoutes.GET("/service-accounts/:user_id/keys", middleware.RequireAccess(models.PermissionServiceAccountsRead), controllers.GetAPIKeys())
+	incomingRoutes.POST("/service-accounts/:user_id/keys", middleware.RequireAccess(models.PermissionServiceAccountsWrite), controllers.CreateAPIKey())
+	incomingRoutes.DELETE("/service-accounts/:user_id/keys/:key_id", middleware.RequireAccess(models.PermissionServiceAccountsWrite), controllers.RevokeAPIKey())
+}
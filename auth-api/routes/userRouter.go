@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/kareem717/auth-api/controllers"
 	"github.com/kareem717/auth-api/middleware"
+	"github.com/kareem717/auth-api/models"
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,7 +12,21 @@ func UserRoutes(incomingRoutes *gin.Engine){
 	// Uses the `Authenticate()` middleware on all routes to check for a valid JWT token in the request header.
 	incomingRoutes.Use(middleware.Authenticate())
 	
-	incomingRoutes.GET("/users", controllers.GetUsers())
-	incomingRoutes.GET("/users/:user_id", controllers.GetUser())
+	// Routes check both the scope of the token and, for other users' data, the permissions of its user.
+	// Listing users is authorized by `GetUsers()`, as the organization of the token decides which users are listed.
+	incomingRoutes.GET("/users", middleware.RequireScopes(models.PermissionUsersRead), controllers.GetUsers())
+	incomingRoutes.GET("/users/:user_id", middleware.RequireScopes(models.PermissionUsersRead), controllers.GetUser())
+	incomingRoutes.POST("/users/:user_id/unlock", middleware.RequireAccess(models.PermissionUsersWrite), controllers.UnlockUser())
+
+	// Routes acting on the user's own account.
+	account := middleware.RequireScopes(models.ScopeAccount)
+	incomingRoutes.POST("/users/password", account, controllers.ChangePassword())
+
+	incomingRoutes.POST("/users/mfa/totp/enroll", account, controllers.EnrollTOTP())
+	incomingRoutes.POST("/users/mfa/totp/confirm", account, controllers.ConfirmTOTP())
+	incomingRoutes.POST("/users/mfa/recovery-codes", account, controllers.RegenerateRecoveryCodes())
+
+	incomingRoutes.POST("/users/webauthn/register/begin", account, controllers.BeginWebAuthnRegistration())
+	incomingRoutes.POST("/users/webauthn/register/finish", account, controllers.FinishWebAuthnRegistration())
 }
 